@@ -15,11 +15,58 @@
 //	// Serve any error as an HTTP response
 //	webutil.ServeError(w, req, err)
 //
+// Use [NewHTTPError] to attach a client-facing message, response headers or an
+// underlying cause to a status code:
+//
+//	return webutil.NewHTTPError(http.StatusNotFound, "invoice not found").WithCause(err)
+//
+// The cause is never shown to clients. Causes of 5xx errors are passed to
+// [ErrorLogger] along with the correlation ID sent as the X-Error-Id header.
+//
+// Use [NewUnauthorized] to send specific authentication challenges, such as
+// RFC 6750 Bearer challenges, with a 401 error. [DefaultAuthChallenge] controls
+// the challenge sent with a plain [StatusUnauthorized].
+//
+// Aggregate errors built with errors.Join are served with the most severe status
+// of their members and list every member's message, keeping the headers of
+// the members with that status such as challenges. [NewMultiStatus] reports
+// per-item results of batch operations as a 207 Multi-Status response instead.
+//
 // Pre-defined status constants are available for all standard 4xx and 5xx
 // HTTP status codes (e.g., [StatusNotFound], [StatusInternalServerError]).
 //
+// Error responses are rendered according to the request's Accept header as
+// plain text, JSON, RFC 9457 problem details or HTML. [NegotiateContentType]
+// exposes the same negotiation logic for use in other handlers.
+//
+// The output format can be customized by installing an [ErrorRenderer], either
+// globally through [DefaultErrorRenderer] or per handler through the Renderer
+// field of [Wrapper]:
+//
+//	webutil.DefaultErrorRenderer = &webutil.StatusErrorRenderer{
+//	    Renderers: map[int]webutil.ErrorRenderer{
+//	        http.StatusNotFound: &webutil.TemplateErrorRenderer{Template: notFoundTmpl},
+//	    },
+//	}
+//
+// The [Problem] type represents RFC 9457 problem details. It can be returned as
+// an error like [HTTPError], and [ReadProblem] decodes problem responses on the
+// client side:
+//
+//	return &webutil.Problem{
+//	    Type:   "https://example.com/probs/out-of-credit",
+//	    Status: http.StatusForbidden,
+//	    Detail: "Your current balance is 30, but that costs 50.",
+//	}
+//
+// Setting [HideErrorDetails] prevents internal error messages from reaching
+// clients: 5xx errors are replaced by a generic message with a correlation ID,
+// and the original error is passed to [ErrorLogger].
+//
 // The [HTTPStatus] function extracts HTTP status codes from any error, including
-// standard library fs errors, wrapped errors, and custom error types.
+// standard library fs, context and network errors, wrapped errors, and custom
+// error types. Applications can map their own sentinel errors to status codes
+// with [RegisterErrorStatus].
 //
 // # Error-Returning Handlers
 //
@@ -40,6 +87,37 @@
 //
 // The [WrapFunc] type provides a function-based alternative similar to http.HandlerFunc.
 //
+// Cross-cutting concerns can be written as [Middleware] and composed with
+// [Chain], errors propagating up to the [Wrapper] that serves them:
+//
+//	http.Handle("/api/", webutil.Wrap(webutil.Chain(logging, requireAuth).Then(api)))
+//
+// [HandlerFunc] adapts functions to the Handler interface, and
+// [AdaptMiddleware] converts standard net/http middlewares.
+//
+// The [Router] dispatches requests to Handlers by method and path pattern,
+// with path parameters read through [PathParam]. Unmatched paths and methods
+// are returned as [StatusNotFound] and [StatusMethodNotAllowed] errors:
+//
+//	r := webutil.NewRouter()
+//	r.HandleFunc(http.MethodGet, "/users/{id}", getUser)
+//	r.Mount("/admin", adminRouter)
+//	http.Handle("/", webutil.Wrap(r))
+//
+// [JSON] adapts a typed function to a Handler decoding a JSON request body and
+// encoding the result, rejecting invalid requests with 4xx errors:
+//
+//	r.Handle(http.MethodPost, "/users", webutil.JSON(createUser))
+//
+// Panics in handlers can be recovered and served as 500 errors by setting
+// [RecoverPanics], or the Recover field of a [Wrapper]. Recovered panics are
+// reported to [PanicLogger] as a [PanicError] including the stack trace and the
+// correlation ID sent to the client, and are not passed to [ErrorLogger].
+//
+// Errors returned after a handler has started writing its response cannot be
+// served with their own status; the response is aborted instead, or the error
+// is sent in a trailer when [ErrorTrailer] is set.
+//
 // # HTTP Redirects
 //
 // The [Redirect] type represents HTTP redirects as errors, allowing redirects to be
@@ -66,6 +144,43 @@
 //
 // The function also supports data: URIs, decoding embedded content directly.
 //
+// A [Getter] configures the HTTP client, request headers and timeouts, and
+// [GetContext] aborts the download when its context is cancelled:
+//
+//	g := &webutil.Getter{
+//	    Header:       http.Header{"Authorization": {"Bearer " + token}},
+//	    StallTimeout: time.Minute,
+//	    Timeout:      time.Hour,
+//	}
+//	reader, err := g.GetContext(ctx, "https://example.com/large-file.zip")
+//
+// Interrupted downloads are resumed following the Getter's [RetryPolicy]: a
+// limited number of attempts with jittered exponential backoff, honoring the
+// Retry-After header of 429 and 503 responses. Other error statuses such as 404
+// or 416 end the download, and a [RetryError] lists the failure of each attempt.
+// Downloads are only resumed if the resource is unchanged: its ETag or
+// Last-Modified date is sent as If-Range and the Content-Range of the response
+// verified, a [ResourceChangedError] being returned otherwise. Downloads ask
+// for the identity encoding unless Getter.Header sets Accept-Encoding, so that
+// offsets and validators refer to the same representation.
+//
+// Servers that ignore Range headers or announce "Accept-Ranges: none" cannot
+// resume downloads, which then fail with [ErrRangeNotSupported]. Setting
+// Getter.MaxSkip allows downloading the resource again from the start instead,
+// discarding up to MaxSkip bytes already read.
+//
+// Large resources can be downloaded over several connections with
+// [Getter.GetSegmented], which reassembles segments fetched with concurrent
+// Range requests in order, or [Getter.DownloadSegmented], which writes them to
+// an io.WriterAt. Each segment is resumed independently:
+//
+//	g := &webutil.Getter{Segments: 8, SegmentSize: 16 << 20}
+//	n, err := g.DownloadSegmented(ctx, "https://example.com/large-file.zip", file)
+//
+// Non-successful responses are returned as an [HTTPResponseError], which keeps
+// the beginning of the body and any JSON or problem+json error message. The
+// [ResponseError] function builds the same error from any http.Response.
+//
 // # Data URI Parsing
 //
 // The [ParseDataURI] function parses RFC 2397 data URIs:
@@ -90,6 +205,41 @@
 //	// Encode back to query string
 //	query := webutil.EncodePhpQuery(result)
 //
+// Encoding is deterministic and matches PHP's http_build_query. [BuildPhpQuery]
+// also encodes structs and selects RFC 1738 or RFC 3986 escaping.
+//
+// Indices follow PHP semantics: a[5]=x&a[]=y stores y at index 6, and arrays
+// are returned as []any only when their keys are 0 to n-1 in order.
+// [ParsePhpQueryOrdered] returns ordered [PhpArray] values instead of maps,
+// preserving the order of keys in the query string.
+//
+// [ParsePhpStr] reproduces PHP's parse_str exactly, including its handling of
+// dots, spaces and unbalanced brackets in variable names.
+//
+// Like PHP, parsing stops after [DefaultPhpMaxInputVars] variables and ignores
+// variables nested deeper than [DefaultPhpMaxNestingLevel]. A [PhpQueryParser]
+// configures these limits and reports them as a [PhpQueryLimitError]:
+//
+//	p := &webutil.PhpQueryParser{MaxInputVars: 100, MaxLength: 8 << 10}
+//	vars, err := p.Parse(req.URL.RawQuery) // 413 or 400 when a limit is exceeded
+//
+// Its ParseForm and Bind methods apply the same limits to request bodies and
+// struct binding.
+//
+// [BindPhpQuery] and [BindPhpMap] decode the same structures into Go structs,
+// slices and maps using `php` struct tags, reporting invalid values as a
+// [BindError] served as 400 Bad Request:
+//
+//	var f struct {
+//	    Tags []string `php:"tags"`
+//	    Page int      `php:"page"`
+//	}
+//	err := webutil.BindPhpQuery(req.URL.Query(), &f)
+//
+// [ParsePhpForm] parses urlencoded and multipart request bodies into the same
+// structure, with uploaded files stored as *multipart.FileHeader values like
+// PHP's $_FILES.
+//
 // This is useful for interoperability with PHP applications or APIs that use
 // this query string format.
 //
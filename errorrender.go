@@ -0,0 +1,240 @@
+package webutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+// Media types supported when rendering error responses.
+const (
+	mimeTextPlain   = "text/plain"
+	mimeJSON        = "application/json"
+	mimeProblemJSON = "application/problem+json"
+	mimeHTML        = "text/html"
+)
+
+// errorMediaTypes lists the formats offered for errors, the first entry being
+// used when the client expresses no preference.
+var errorMediaTypes = []string{mimeTextPlain, mimeJSON, mimeProblemJSON, mimeHTML}
+
+// problemMediaTypes is used instead of errorMediaTypes when serving a Problem.
+var problemMediaTypes = []string{mimeProblemJSON, mimeJSON, mimeHTML, mimeTextPlain}
+
+// ErrorRenderer writes the HTTP response for an error.
+//
+// RenderError is called with the status code that should be sent and the
+// error being served. Headers specific to the error (such as WWW-Authenticate)
+// have already been set on w when it is called.
+type ErrorRenderer interface {
+	RenderError(w http.ResponseWriter, req *http.Request, code int, err error)
+}
+
+// ErrorRendererFunc is an adapter allowing ordinary functions to be used as
+// an ErrorRenderer.
+type ErrorRendererFunc func(w http.ResponseWriter, req *http.Request, code int, err error)
+
+// RenderError calls f(w, req, code, err).
+func (f ErrorRendererFunc) RenderError(w http.ResponseWriter, req *http.Request, code int, err error) {
+	f(w, req, code, err)
+}
+
+// DefaultErrorRenderer is the renderer used by HTTPError, ServeError and the
+// error-returning handler wrappers when no renderer has been set on the
+// request through a Wrapper or WithErrorRenderer. If nil, StandardErrorRenderer
+// is used.
+//
+// It should be set during program initialization, before any request is served.
+var DefaultErrorRenderer ErrorRenderer = StandardErrorRenderer{}
+
+// errorRendererKey is the context key holding a request's ErrorRenderer.
+type errorRendererKey struct{}
+
+// WithErrorRenderer returns a copy of ctx in which errors served by this
+// package are rendered using r.
+func WithErrorRenderer(ctx context.Context, r ErrorRenderer) context.Context {
+	return context.WithValue(ctx, errorRendererKey{}, r)
+}
+
+// errorRendererFor returns the ErrorRenderer to use for the given request.
+func errorRendererFor(req *http.Request) ErrorRenderer {
+	if r, ok := req.Context().Value(errorRendererKey{}).(ErrorRenderer); ok && r != nil {
+		return r
+	}
+	if DefaultErrorRenderer != nil {
+		return DefaultErrorRenderer
+	}
+	return StandardErrorRenderer{}
+}
+
+// renderError writes the response for err using the request's ErrorRenderer.
+func renderError(w http.ResponseWriter, req *http.Request, code int, err error) {
+	errorRendererFor(req).RenderError(w, req, code, err)
+}
+
+// errorMessage returns the message to display for err. An empty string means
+// the status code carries no further detail.
+func errorMessage(err error) string {
+	switch e := err.(type) {
+	case HTTPError:
+		return ""
+	case PublicError:
+		return e.PublicMessage()
+	default:
+		return err.Error()
+	}
+}
+
+// StandardErrorRenderer is the built-in ErrorRenderer. It renders errors as
+// plain text, JSON ({"error": ..., "code": ...}), RFC 9457 problem details or
+// HTML depending on the request's Accept header, defaulting to plain text.
+//
+// A Problem is rendered with all its members, and defaults to
+// application/problem+json. Aggregate errors, such as those returned by
+// errors.Join or a MultiStatus, are rendered with the status and message of
+// each member.
+type StandardErrorRenderer struct{}
+
+// jsonErrorBody is the body sent for errors when the client accepts application/json.
+type jsonErrorBody struct {
+	Error  string      `json:"error"`
+	Code   int         `json:"code"`
+	Errors []errorItem `json:"errors,omitempty"` // members of aggregate errors
+}
+
+// RenderError implements ErrorRenderer.
+func (StandardErrorRenderer) RenderError(w http.ResponseWriter, req *http.Request, code int, err error) {
+	title := statusText(code)
+	msg := errorMessage(err)
+
+	var problem *Problem
+	var items []errorItem
+	offers := errorMediaTypes
+	if members := errorMembers(err); members != nil {
+		// Aggregate errors list each member after the status
+		items = errorItems(members)
+		msg = ""
+	} else if errors.As(err, &problem) {
+		offers = problemMediaTypes
+		msg = problem.Detail
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	switch NegotiateContentType(req, offers...) {
+	case mimeJSON:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		if problem != nil {
+			_ = json.NewEncoder(w).Encode(problem)
+			return
+		}
+		if msg == "" {
+			msg = title
+		}
+		_ = json.NewEncoder(w).Encode(&jsonErrorBody{Error: msg, Code: code, Errors: items})
+	case mimeProblemJSON:
+		if problem == nil {
+			if msg == title {
+				msg = ""
+			}
+			problem = &Problem{Title: title, Status: code, Detail: msg}
+			if items != nil {
+				problem.Extensions = map[string]any{"errors": items}
+			}
+		}
+		w.Header().Set("Content-Type", mimeProblemJSON)
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(problem)
+	case mimeHTML:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(code)
+		heading := html.EscapeString(fmt.Sprintf("%d %s", code, title))
+		_, _ = fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>%s</title></head><body><h1>%s</h1>", heading, heading)
+		if msg != "" && msg != title {
+			_, _ = fmt.Fprintf(w, "<p>%s</p>", html.EscapeString(msg))
+		}
+		if items != nil {
+			_, _ = io.WriteString(w, "<ul>")
+			for _, item := range items {
+				_, _ = fmt.Fprintf(w, "<li>%d %s</li>", item.Code, html.EscapeString(item.message()))
+			}
+			_, _ = io.WriteString(w, "</ul>")
+		}
+		_, _ = io.WriteString(w, "</body></html>\n")
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		if items != nil {
+			_, _ = fmt.Fprintf(w, "%d %s\n", code, title)
+			for _, item := range items {
+				_, _ = fmt.Fprintf(w, "%d %s\n", item.Code, item.message())
+			}
+			return
+		}
+		if msg == "" {
+			msg = fmt.Sprintf("HTTP Error code %d: %s", code, title)
+		}
+		_, _ = io.WriteString(w, msg)
+	}
+}
+
+// StatusErrorRenderer dispatches rendering to a different ErrorRenderer
+// depending on the status code, for example to serve a dedicated page for
+// 404 errors.
+type StatusErrorRenderer struct {
+	// Renderers maps status codes to their renderer
+	Renderers map[int]ErrorRenderer
+	// Fallback is used for codes not in Renderers, StandardErrorRenderer if nil
+	Fallback ErrorRenderer
+}
+
+// RenderError implements ErrorRenderer.
+func (s *StatusErrorRenderer) RenderError(w http.ResponseWriter, req *http.Request, code int, err error) {
+	if r, ok := s.Renderers[code]; ok && r != nil {
+		r.RenderError(w, req, code, err)
+		return
+	}
+	if s.Fallback != nil {
+		s.Fallback.RenderError(w, req, code, err)
+		return
+	}
+	StandardErrorRenderer{}.RenderError(w, req, code, err)
+}
+
+// ErrorPage holds the data passed to the template of a TemplateErrorRenderer.
+type ErrorPage struct {
+	Code    int           // HTTP status code
+	Title   string        // Standard status text for Code
+	Message string        // Message describing the error, may be empty
+	Request *http.Request // Request being served
+	Err     error         // Error being rendered
+}
+
+// TemplateErrorRenderer renders errors as HTML using an html/template, which
+// is executed with an *ErrorPage.
+type TemplateErrorRenderer struct {
+	Template *template.Template
+}
+
+// RenderError implements ErrorRenderer.
+func (t *TemplateErrorRenderer) RenderError(w http.ResponseWriter, req *http.Request, code int, err error) {
+	page := &ErrorPage{
+		Code:    code,
+		Title:   statusText(code),
+		Message: errorMessage(err),
+		Request: req,
+		Err:     err,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_ = t.Template.Execute(w, page)
+}
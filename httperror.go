@@ -1,9 +1,9 @@
 package webutil
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"io/fs"
 	"net/http"
 )
@@ -37,11 +37,12 @@ func HttpErrorHandler(code int) http.Handler {
 
 // Error returns a formatted string with the HTTP error code and text.
 func (e HTTPError) Error() string {
-	return fmt.Sprintf("HTTP error %d: %s", e, http.StatusText(int(e)))
+	return fmt.Sprintf("HTTP error %d: %s", e, statusText(int(e)))
 }
 
-// Unwrap maps HTTP errors to standard filesystem errors when appropriate,
-// making it easier to check if a response matches a specific kind of error.
+// Unwrap maps HTTP errors to standard filesystem and context errors when
+// appropriate, making it easier to check if a response matches a specific
+// kind of error. This is the reverse of the mapping done by HTTPStatus.
 func (e HTTPError) Unwrap() error {
 	switch e {
 	case http.StatusBadRequest:
@@ -50,38 +51,42 @@ func (e HTTPError) Unwrap() error {
 		return fs.ErrPermission
 	case http.StatusNotFound:
 		return fs.ErrNotExist
+	case http.StatusConflict:
+		return fs.ErrExist
+	case StatusClientClosedRequest:
+		return context.Canceled
+	case http.StatusGatewayTimeout:
+		return context.DeadlineExceeded
 	default:
 		return nil
 	}
 }
 
 // ServeHTTP implements the http.Handler interface to serve the HTTP error.
+// The response is written by the request's ErrorRenderer.
 func (e HTTPError) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-
-	// Add WWW-Authenticate header for 401 Unauthorized
+	// Add WWW-Authenticate header for 401 Unauthorized, see DefaultAuthChallenge
 	if e == http.StatusUnauthorized {
-		w.Header().Set("WWW-Authenticate", "Basic realm=\"Website Access\"")
+		setDefaultChallenge(w.Header())
 	}
 
-	w.WriteHeader(int(e))
-	_, _ = fmt.Fprintf(w, "HTTP Error code %d: %s", int(e), http.StatusText(int(e)))
+	renderError(w, req, int(e), e)
 }
 
 // ServeHTTP implements http.Handler for serverError to serve an error via HTTP
+// using the request's ErrorRenderer. Error details are hidden from the client
+// when HideErrorDetails is enabled.
 func (e *serverError) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-
 	// Get HTTP status code from error or default to 500
 	code := HTTPStatus(e.e)
 	if code == 0 {
 		code = http.StatusInternalServerError
 	}
 
-	w.WriteHeader(code)
-	_, _ = io.WriteString(w, e.e.Error())
+	if members := errorMembers(e.e); members != nil {
+		addMemberHeaders(w.Header(), members, code)
+	}
+	renderError(w, req, code, sanitizeError(w, req, code, e.e))
 }
 
 // HTTPStatus returns the numeric HTTP status code represented by this error.
@@ -136,9 +141,12 @@ func (h *handlerError) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 // ServeError serves an error via HTTP.
 // If the error can be type-asserted to an http.Handler, it uses that.
 // Otherwise, it wraps the error in a serverError and serves that.
+// Aggregate errors such as those returned by errors.Join are served with the
+// most severe status of their members, listing each member's message, along
+// with the headers of the members having that status.
 func ServeError(w http.ResponseWriter, req *http.Request, err error) {
 	var h http.Handler
-	if errors.As(err, &h) {
+	if !isAggregate(err) && errors.As(err, &h) {
 		h.ServeHTTP(w, req)
 		return
 	}
@@ -0,0 +1,450 @@
+package webutil_test
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+// serve runs handler h for a GET request with the given Accept header and
+// returns the recorded response.
+func serve(h http.Handler, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// errorHandler is a webutil.Handler always returning the same error.
+type errorHandler struct {
+	err error
+}
+
+func (h errorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
+	return h.err
+}
+
+func TestErrorContentNegotiation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		handler     http.Handler
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "No Accept header",
+			handler:     webutil.StatusNotFound,
+			code:        http.StatusNotFound,
+			contentType: "text/plain; charset=utf-8",
+			body:        "HTTP Error code 404: Not Found",
+		},
+		{
+			name:        "JSON",
+			handler:     webutil.StatusNotFound,
+			accept:      "application/json",
+			code:        http.StatusNotFound,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"error":"Not Found","code":404}`,
+		},
+		{
+			name:        "Problem details",
+			handler:     webutil.StatusForbidden,
+			accept:      "application/problem+json, application/json;q=0.9",
+			code:        http.StatusForbidden,
+			contentType: "application/problem+json",
+			body:        `{"type":"about:blank","title":"Forbidden","status":403}`,
+		},
+		{
+			name:        "Browser",
+			handler:     webutil.StatusNotFound,
+			accept:      "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			code:        http.StatusNotFound,
+			contentType: "text/html; charset=utf-8",
+			body:        "<h1>404 Not Found</h1>",
+		},
+		{
+			name:        "Wildcard",
+			handler:     webutil.StatusNotFound,
+			accept:      "*/*",
+			code:        http.StatusNotFound,
+			contentType: "text/plain; charset=utf-8",
+			body:        "HTTP Error code 404: Not Found",
+		},
+		{
+			name:        "Server error as JSON",
+			handler:     webutil.ErrorToHTTPHandler(errors.New("something broke")),
+			accept:      "application/json, */*",
+			code:        http.StatusInternalServerError,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"error":"something broke","code":500}`,
+		},
+		{
+			name:        "Server error as plain text",
+			handler:     webutil.ErrorToHTTPHandler(errors.New("something broke")),
+			code:        http.StatusInternalServerError,
+			contentType: "text/plain; charset=utf-8",
+			body:        "something broke",
+		},
+		{
+			name: "Wrapped handler",
+			handler: webutil.WrapFunc(func(w http.ResponseWriter, req *http.Request) error {
+				return webutil.StatusConflict
+			}),
+			accept:      "application/json",
+			code:        http.StatusConflict,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"error":"Conflict","code":409}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(tc.handler, tc.accept)
+
+			if rec.Code != tc.code {
+				t.Errorf("Status mismatch: got %d, want %d", rec.Code, tc.code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Errorf("Content-Type mismatch: got %q, want %q", ct, tc.contentType)
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tc.body) {
+				t.Errorf("Body mismatch: got %q, want it to contain %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{"text/plain", "application/json", "text/html"}
+
+	testCases := []struct {
+		accept string
+		expect string
+	}{
+		{"", "text/plain"},
+		{"application/json", "application/json"},
+		{"text/*", "text/plain"},
+		{"text/html;q=0.5, application/json;q=0.4", "text/html"},
+		{"application/json, */*", "application/json"},
+		{"image/png", "text/plain"},
+		{"text/plain;q=0, */*", "application/json"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			if got := webutil.NegotiateContentType(req, offers...); got != tc.expect {
+				t.Errorf("NegotiateContentType(%q): got %q, want %q", tc.accept, got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestErrorRenderer(t *testing.T) {
+	branded := webutil.ErrorRendererFunc(func(w http.ResponseWriter, req *http.Request, code int, err error) {
+		w.WriteHeader(code)
+		_, _ = io.WriteString(w, "branded: "+err.Error())
+	})
+	notFound := &webutil.TemplateErrorRenderer{
+		Template: template.Must(template.New("404").Parse("<p>{{.Code}} {{.Title}}: {{.Request.URL.Path}}</p>")),
+	}
+
+	renderer := &webutil.StatusErrorRenderer{
+		Renderers: map[int]webutil.ErrorRenderer{http.StatusNotFound: notFound},
+		Fallback:  branded,
+	}
+
+	testCases := []struct {
+		name string
+		err  error
+		code int
+		body string
+	}{
+		{"Per-status renderer", webutil.StatusNotFound, http.StatusNotFound, "<p>404 Not Found: /</p>"},
+		{"Fallback renderer", errors.New("oops"), http.StatusInternalServerError, "branded: oops"},
+		{"Fallback for HTTPError", webutil.StatusGone, http.StatusGone, "branded: HTTP error 410: Gone"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wrapper := &webutil.Wrapper{Child: errorHandler{tc.err}, Renderer: renderer}
+			rec := serve(wrapper, "")
+
+			if rec.Code != tc.code {
+				t.Errorf("Status mismatch: got %d, want %d", rec.Code, tc.code)
+			}
+			if body := rec.Body.String(); body != tc.body {
+				t.Errorf("Body mismatch: got %q, want %q", body, tc.body)
+			}
+		})
+	}
+}
+
+// publicErr is an error with a client-safe message.
+type publicErr struct{}
+
+func (publicErr) Error() string         { return "lookup failed: /var/db/users.db: corrupted" }
+func (publicErr) PublicMessage() string { return "user not found" }
+func (publicErr) HTTPStatus() int       { return http.StatusNotFound }
+
+func TestHideErrorDetails(t *testing.T) {
+	webutil.HideErrorDetails = true
+	var logged error
+	var loggedID string
+	webutil.ErrorLogger = func(req *http.Request, err error, id string) {
+		logged, loggedID = err, id
+	}
+	defer func() {
+		webutil.HideErrorDetails = false
+		webutil.ErrorLogger = nil
+	}()
+
+	internal := errors.New("pq: connection refused to 10.0.0.5")
+	rec := serve(webutil.ErrorToHTTPHandler(internal), "")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("Internal error leaked to client: %q", rec.Body.String())
+	}
+	if logged != internal {
+		t.Errorf("Logger got %v, want %v", logged, internal)
+	}
+	if id := rec.Header().Get("X-Error-Id"); id == "" || id != loggedID || !strings.Contains(rec.Body.String(), id) {
+		t.Errorf("Correlation ID mismatch: header %q, logged %q, body %q", id, loggedID, rec.Body.String())
+	}
+
+	// 4xx with a public message shows that message only
+	rec = serve(webutil.ErrorToHTTPHandler(publicErr{}), "")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "user not found" {
+		t.Errorf("Public error: got %d %q, want 404 %q", rec.Code, rec.Body.String(), "user not found")
+	}
+
+	// 4xx without a public message shows the status text
+	rec = serve(webutil.ErrorToHTTPHandler(fmt.Errorf("open /etc/secret: %w", fs.ErrNotExist)), "")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "Not Found" {
+		t.Errorf("Private 4xx error: got %d %q, want 404 %q", rec.Code, rec.Body.String(), "Not Found")
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	cause := errors.New("sql: no rows in result set")
+	err := fmt.Errorf("fetching invoice: %w",
+		webutil.NewHTTPError(http.StatusNotFound, "invoice not found").WithCause(cause))
+
+	if code := webutil.HTTPStatus(err); code != http.StatusNotFound {
+		t.Errorf("HTTPStatus: got %d, want %d", code, http.StatusNotFound)
+	}
+	if !errors.Is(err, webutil.StatusNotFound) {
+		t.Errorf("errors.Is(err, StatusNotFound) = false, want true")
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("errors.Is(err, fs.ErrNotExist) = false, want true")
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("errors.Is(err, cause) = false, want true")
+	}
+	if errors.Is(err, webutil.StatusGone) {
+		t.Errorf("errors.Is(err, StatusGone) = true, want false")
+	}
+
+	rec := httptest.NewRecorder()
+	webutil.ServeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), err)
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "invoice not found" {
+		t.Errorf("Served error: got %d %q, want 404 %q", rec.Code, rec.Body.String(), "invoice not found")
+	}
+
+	// The error's own status wins over the one of its cause
+	err = webutil.NewHTTPError(http.StatusTooManyRequests, "").
+		WithHeader("Retry-After", "30").
+		WithCause(fs.ErrNotExist)
+	if code := webutil.HTTPStatus(err); code != http.StatusTooManyRequests {
+		t.Errorf("HTTPStatus: got %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	rec = serve(err.(http.Handler), "application/json")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if ra := rec.Header().Get("Retry-After"); ra != "30" {
+		t.Errorf("Retry-After mismatch: got %q, want %q", ra, "30")
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"error":"Too Many Requests"`) {
+		t.Errorf("Body mismatch: got %q", body)
+	}
+}
+
+func TestStatusErrorCauseLogged(t *testing.T) {
+	var logged []error
+	var loggedID string
+	webutil.ErrorLogger = func(req *http.Request, err error, id string) {
+		logged = append(logged, err)
+		loggedID = id
+	}
+	defer func() { webutil.ErrorLogger = nil }()
+
+	dbErr := errors.New("pq: connection refused to 10.0.0.5")
+	testCases := []struct {
+		name string
+		err  error
+		body string
+		logs int
+	}{
+		{"StatusError", webutil.NewHTTPError(http.StatusServiceUnavailable, "try later").WithCause(dbErr), "try later", 1},
+		{"Problem", &webutil.Problem{Status: http.StatusInternalServerError, Title: "Internal Server Error", Detail: "try later", Err: dbErr}, "try later", 1},
+		{"ClientError", webutil.NewHTTPError(http.StatusNotFound, "not found").WithCause(dbErr), "not found", 0},
+		{"NoCause", webutil.NewHTTPError(http.StatusServiceUnavailable, "try later"), "try later", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logged, loggedID = nil, ""
+			rec := serve(webutil.WrapFunc(func(w http.ResponseWriter, req *http.Request) error {
+				return tc.err
+			}), "")
+
+			if !strings.Contains(rec.Body.String(), tc.body) || strings.Contains(rec.Body.String(), "10.0.0.5") {
+				t.Errorf("Body mismatch: got %q", rec.Body.String())
+			}
+			if len(logged) != tc.logs {
+				t.Fatalf("Expected %d logged errors, got %d", tc.logs, len(logged))
+			}
+			if tc.logs == 0 {
+				return
+			}
+			if !errors.Is(logged[0], dbErr) {
+				t.Errorf("Logged %v, want cause %v", logged[0], dbErr)
+			}
+			if id := rec.Header().Get("X-Error-Id"); id == "" || id != loggedID {
+				t.Errorf("Correlation ID mismatch: header %q, logged %q", id, loggedID)
+			}
+		})
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	digest := webutil.DigestChallenge("api", "abc", "")
+	digest.Params = append(digest.Params, webutil.AuthParam{Name: "stale", Value: "true", Token: true})
+	err := webutil.NewUnauthorized(
+		webutil.BearerChallenge("api", "invalid_token", `token "abc" expired`, ""),
+		webutil.BasicChallenge("api"),
+		digest,
+	)
+	if !errors.Is(err, webutil.StatusUnauthorized) {
+		t.Errorf("errors.Is(err, StatusUnauthorized) = false, want true")
+	}
+
+	rec := serve(err, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	expect := []string{
+		`Bearer realm="api", error="invalid_token", error_description="token \"abc\" expired"`,
+		`Basic realm="api", charset="UTF-8"`,
+		`Digest realm="api", qop="auth", algorithm=SHA-256, nonce="abc", stale=true`,
+	}
+	got := rec.Header().Values("WWW-Authenticate")
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("WWW-Authenticate mismatch: got %q, want %q", got, expect)
+	}
+
+	// The default challenge can be disabled globally
+	rec = serve(webutil.StatusUnauthorized, "")
+	if h := rec.Header().Get("WWW-Authenticate"); h != `Basic realm="Website Access"` {
+		t.Errorf("Default challenge mismatch: got %q", h)
+	}
+
+	webutil.DefaultAuthChallenge = ""
+	defer func() { webutil.DefaultAuthChallenge = `Basic realm="Website Access"` }()
+
+	rec = serve(webutil.StatusUnauthorized, "")
+	if h, ok := rec.Header()["Www-Authenticate"]; ok {
+		t.Errorf("Disabled default challenge still sent: %q", h)
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	joined := errors.Join(
+		webutil.NewHTTPError(http.StatusBadRequest, "item 0: missing name"),
+		webutil.StatusNotFound,
+		webutil.NewHTTPError(http.StatusConflict, "item 2: duplicate"),
+	)
+
+	if code := webutil.HTTPStatus(joined); code != http.StatusBadRequest {
+		t.Errorf("HTTPStatus: got %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := webutil.HTTPStatus(errors.Join(webutil.StatusNotFound, webutil.StatusBadGateway)); code != http.StatusBadGateway {
+		t.Errorf("HTTPStatus with 5xx member: got %d, want %d", code, http.StatusBadGateway)
+	}
+	if code := webutil.HTTPStatus(errors.Join(webutil.StatusNotFound, errors.New("boom"))); code != http.StatusInternalServerError {
+		t.Errorf("HTTPStatus with unknown member: got %d, want %d", code, http.StatusInternalServerError)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/batch", nil)
+	req.Header.Set("Accept", "application/json")
+	webutil.ServeError(rec, req, fmt.Errorf("batch failed: %w", joined))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	expect := `{"error":"Bad Request","code":400,"errors":[{"code":400,"error":"item 0: missing name"},` +
+		`{"code":404,"error":"Not Found"},{"code":409,"error":"item 2: duplicate"}]}`
+	if body := strings.TrimSpace(rec.Body.String()); body != expect {
+		t.Errorf("Body mismatch:\ngot  %s\nwant %s", body, expect)
+	}
+
+	// Headers of the members having the chosen status are kept
+	rec = httptest.NewRecorder()
+	webutil.ServeError(rec, req, errors.Join(
+		webutil.NewUnauthorized(webutil.BearerChallenge("api", "invalid_token", "", "")),
+		webutil.StatusNotFound,
+	))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if h := rec.Header().Values("WWW-Authenticate"); len(h) != 1 || h[0] != `Bearer realm="api", error="invalid_token"` {
+		t.Errorf("WWW-Authenticate mismatch: got %q", h)
+	}
+
+	rec = httptest.NewRecorder()
+	webutil.ServeError(rec, req, errors.Join(
+		webutil.NewHTTPError(http.StatusBadRequest, "item 0: invalid").WithHeader("X-Item", "0"),
+		webutil.NewHTTPError(http.StatusServiceUnavailable, "item 1: maintenance").WithHeader("Retry-After", "30"),
+	))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if h := rec.Header().Get("Retry-After"); h != "30" {
+		t.Errorf("Retry-After mismatch: got %q, want %q", h, "30")
+	}
+	if h := rec.Header().Get("X-Item"); h != "" {
+		t.Errorf("Header of a member with another status sent: X-Item %q", h)
+	}
+
+	// MultiStatus keeps successful items
+	rec = serve(webutil.NewMultiStatus(nil, webutil.StatusNotFound), "")
+	if rec.Code != http.StatusMultiStatus {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusMultiStatus)
+	}
+	if body := rec.Body.String(); body != "207 Multi-Status\n200 OK\n404 Not Found\n" {
+		t.Errorf("Body mismatch: got %q", body)
+	}
+}
@@ -1,56 +1,139 @@
 package webutil
 
 import (
-	"errors"
+	"context"
 	"io/fs"
+	"net"
 	"net/http"
+	"os"
+	"reflect"
+	"sync"
 )
 
+// errorStatus maps a sentinel error to an HTTP status code.
+type errorStatus struct {
+	target error
+	code   int
+}
+
+var (
+	errorStatusMu  sync.RWMutex
+	errorStatusReg []errorStatus // registered by RegisterErrorStatus
+)
+
+// builtinErrorStatus lists the standard library errors recognized by HTTPStatus.
+var builtinErrorStatus = []errorStatus{
+	{fs.ErrNotExist, http.StatusNotFound},
+	{fs.ErrPermission, http.StatusForbidden},
+	{fs.ErrExist, http.StatusConflict},
+	{context.Canceled, StatusClientClosedRequest.HTTPStatus()},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout},
+	{os.ErrDeadlineExceeded, http.StatusGatewayTimeout},
+}
+
+// RegisterErrorStatus registers the HTTP status code that HTTPStatus returns
+// for errors matching target, such as an application's sentinel errors:
+//
+//	var ErrInvoiceNotFound = errors.New("invoice not found")
+//
+//	func init() {
+//	    webutil.RegisterErrorStatus(ErrInvoiceNotFound, http.StatusNotFound)
+//	}
+//
+// Registered errors are checked before the built-in mappings, and the most
+// recent registration wins when a target is registered more than once.
+func RegisterErrorStatus(target error, code int) {
+	errorStatusMu.Lock()
+	defer errorStatusMu.Unlock()
+	errorStatusReg = append(errorStatusReg, errorStatus{target, code})
+}
+
 // HTTPStatus extracts an HTTP status code from an error.
 //
-// It can handle several types of errors:
-// 1. Standard fs package errors (ErrNotExist, ErrPermission)
-// 2. HTTPError type from this package
-// 3. Any error that implements HTTPStatus() int
-// 4. Wrapped errors (recursively checks unwrapped errors)
+// The error and the errors it wraps are examined in order, the first match
+// determining the status:
+//  1. Errors with an HTTPStatus() int method, including HTTPError, StatusError,
+//     Problem and Redirect
+//  2. Errors registered with RegisterErrorStatus
+//  3. Standard errors: fs.ErrNotExist (404), fs.ErrPermission (403),
+//     fs.ErrExist (409), context.Canceled (499), context.DeadlineExceeded and
+//     os.ErrDeadlineExceeded (504), *http.MaxBytesError (413) and net.Error
+//     timeouts (504)
+//
+// For aggregate errors such as those returned by errors.Join, the most severe
+// status of the members is returned: 5xx over 4xx over 3xx, the first member
+// winning within a class. Members without a status count as 500 if any other
+// member has a status.
 //
 // Returns 0 if no status code can be determined from the error.
 func HTTPStatus(err error) int {
-	if err == nil {
-		return 0
+	type statusGetter interface {
+		HTTPStatus() int
 	}
 
-	// Check standard fs errors
-	switch {
-	case errors.Is(err, fs.ErrNotExist):
-		return http.StatusNotFound
-	case errors.Is(err, fs.ErrPermission):
-		return http.StatusForbidden
-	}
+	for err != nil {
+		// An error's own status takes precedence over anything it wraps
+		if s, ok := err.(statusGetter); ok {
+			return s.HTTPStatus()
+		}
+		if code := matchErrorStatus(err); code != 0 {
+			return code
+		}
 
-	// Try to extract status from various error types
-	var httpErr HTTPError
-	if errors.As(err, &httpErr) {
-		return int(httpErr)
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Unwrap() []error }:
+			// Aggregates take the most severe status of their members
+			return aggregateStatus(e.Unwrap())
+		default:
+			return 0
+		}
 	}
 
-	// Check for anything implementing HTTPStatus() method
-	type statusGetter interface {
-		HTTPStatus() int
-	}
-	var statusErr statusGetter
-	if errors.As(err, &statusErr) {
-		return statusErr.HTTPStatus()
+	// No status found
+	return 0
+}
+
+// matchErrorStatus returns the status code for err without looking at the
+// errors it wraps, or 0 if err is not a known error.
+func matchErrorStatus(err error) int {
+	errorStatusMu.RLock()
+	for i := len(errorStatusReg) - 1; i >= 0; i-- {
+		if isError(err, errorStatusReg[i].target) {
+			errorStatusMu.RUnlock()
+			return errorStatusReg[i].code
+		}
 	}
+	errorStatusMu.RUnlock()
 
-	// If we have a wrapped error, recursively unwrap and check
-	var unwrapErr interface{ Unwrap() error }
-	if errors.As(err, &unwrapErr) {
-		if unwrapped := unwrapErr.Unwrap(); unwrapped != nil {
-			return HTTPStatus(unwrapped)
+	for _, m := range builtinErrorStatus {
+		if isError(err, m.target) {
+			return m.code
 		}
 	}
 
-	// No status found
+	if _, ok := err.(*http.MaxBytesError); ok {
+		return http.StatusRequestEntityTooLarge
+	}
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+
 	return 0
 }
+
+// isError reports whether err matches target like errors.Is, but without
+// unwrapping err.
+func isError(err, target error) bool {
+	if target == nil {
+		return false
+	}
+	if reflect.TypeOf(target).Comparable() && err == target {
+		return true
+	}
+	if x, ok := err.(interface{ Is(error) bool }); ok && x.Is(target) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package webutil_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+// timeoutErr is a net.Error reporting a timeout.
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+func init() {
+	webutil.RegisterErrorStatus(errQuotaExceeded, http.StatusTooManyRequests)
+}
+
+func TestHTTPStatus(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		expect int
+	}{
+		{"Nil", nil, 0},
+		{"Unknown", errors.New("unknown"), 0},
+		{"HTTPError", webutil.StatusGone, http.StatusGone},
+		{"Wrapped HTTPError", fmt.Errorf("wrapped: %w", webutil.StatusConflict), http.StatusConflict},
+		{"Not exist", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}, http.StatusNotFound},
+		{"Permission", fs.ErrPermission, http.StatusForbidden},
+		{"Exist", fmt.Errorf("create: %w", fs.ErrExist), http.StatusConflict},
+		{"Deadline exceeded", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"Canceled", fmt.Errorf("query: %w", context.Canceled), 499},
+		{"OS deadline", os.ErrDeadlineExceeded, http.StatusGatewayTimeout},
+		{"Max bytes", fmt.Errorf("reading body: %w", &http.MaxBytesError{Limit: 10}), http.StatusRequestEntityTooLarge},
+		{"Net timeout", fmt.Errorf("dial: %w", timeoutErr{}), http.StatusGatewayTimeout},
+		{"Registered", fmt.Errorf("upload: %w", errQuotaExceeded), http.StatusTooManyRequests},
+		{"Wrapped request timeout", fmt.Errorf("x: %w", webutil.StatusRequestTimeout), http.StatusRequestTimeout},
+		{"Redirect", &webutil.Redirect{Code: http.StatusMovedPermanently}, http.StatusMovedPermanently},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := webutil.HTTPStatus(tc.err); got != tc.expect {
+				t.Errorf("HTTPStatus(%v): got %d, want %d", tc.err, got, tc.expect)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorUnwrap(t *testing.T) {
+	testCases := []struct {
+		code   webutil.HTTPError
+		target error
+	}{
+		{webutil.StatusNotFound, fs.ErrNotExist},
+		{webutil.StatusForbidden, fs.ErrPermission},
+		{webutil.StatusConflict, fs.ErrExist},
+		{webutil.StatusGatewayTimeout, context.DeadlineExceeded},
+		{webutil.StatusClientClosedRequest, context.Canceled},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.code.Error(), func(t *testing.T) {
+			if !errors.Is(tc.code, tc.target) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", tc.code, tc.target)
+			}
+			if got := webutil.HTTPStatus(tc.target); got != int(tc.code) {
+				t.Errorf("HTTPStatus(%v): got %d, want %d", tc.target, got, int(tc.code))
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package webutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// DefaultMaxJSONBodySize is the request body size limit of a JSONHandler
+// whose MaxBodySize is zero.
+const DefaultMaxJSONBodySize = 1 << 20 // 1MB
+
+// JSONHandler is a Handler decoding a JSON request body into In, calling Func
+// and encoding the returned Out as a JSON response.
+//
+// Requests with a body must have a JSON Content-Type (application/json or any
+// +json media type), otherwise StatusUnsupportedMediaType is returned. Bodies
+// larger than MaxBodySize are rejected with StatusRequestEntityTooLarge, and
+// malformed JSON with StatusBadRequest, whose message refers to JSON fields and
+// offsets while the decoding error is kept as its Cause. Requests without a
+// body call Func with the zero value of In.
+//
+// If In, or a pointer to it, has a Validate() error method, it is called after
+// decoding. Validation errors that do not carry an HTTP status are returned as
+// StatusBadRequest errors.
+//
+// Errors returned by Func are returned as is and nothing is written, so that
+// they are served by the enclosing Wrapper like any other error.
+type JSONHandler[In, Out any] struct {
+	// Func implements the request logic
+	Func func(ctx context.Context, req *http.Request, in In) (Out, error)
+
+	// MaxBodySize limits the size of request bodies, DefaultMaxJSONBodySize
+	// if zero. A negative value disables the limit.
+	MaxBodySize int64
+
+	// Status is the status code sent on success, 200 if zero. The output of
+	// Func is not sent with statuses that do not allow a body, such as 204
+	// No Content.
+	Status int
+
+	// DisallowUnknownFields rejects bodies with fields not present in In
+	DisallowUnknownFields bool
+}
+
+// JSON returns a JSONHandler calling fn with default settings:
+//
+//	type createUser struct {
+//	    Name string `json:"name"`
+//	}
+//
+//	r.Handle(http.MethodPost, "/users", webutil.JSON(func(ctx context.Context, req *http.Request, in createUser) (*User, error) {
+//	    return users.Create(ctx, in.Name)
+//	}))
+func JSON[In, Out any](fn func(ctx context.Context, req *http.Request, in In) (Out, error)) *JSONHandler[In, Out] {
+	return &JSONHandler[In, Out]{Func: fn}
+}
+
+// ServeHTTP implements the Handler interface.
+func (h *JSONHandler[In, Out]) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
+	var in In
+	if err := h.decode(w, req, &in); err != nil {
+		return err
+	}
+	if err := validateInput(&in, in); err != nil {
+		return err
+	}
+
+	out, err := h.Func(req.Context(), req, in)
+	if err != nil {
+		return err
+	}
+
+	status := h.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if !bodyAllowedForStatus(status) {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	// Encode before writing anything so that encoding errors can still be served
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(out); err != nil {
+		return fmt.Errorf("encoding JSON response: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// bodyAllowedForStatus reports whether a response with the given status may
+// have a body.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// decode reads the JSON request body into in.
+func (h *JSONHandler[In, Out]) decode(w http.ResponseWriter, req *http.Request, in *In) error {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil
+	}
+
+	if !isJSONMediaType(req.Header.Get("Content-Type")) {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "request body must be JSON")
+	}
+
+	limit := h.MaxBodySize
+	if limit == 0 {
+		limit = DefaultMaxJSONBodySize
+	}
+	body := req.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(w, body, limit)
+	}
+
+	dec := json.NewDecoder(body)
+	if h.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(in); err != nil {
+		return jsonDecodeError(err)
+	}
+	// The body must contain a single JSON value
+	if _, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			return NewHTTPError(http.StatusBadRequest, "invalid JSON body: unexpected data after JSON value")
+		}
+		return jsonDecodeError(err)
+	}
+
+	return nil
+}
+
+// jsonDecodeError converts an error returned while decoding a request body to
+// the matching HTTP error.
+func jsonDecodeError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit)).WithCause(err)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return NewHTTPError(http.StatusBadRequest, "truncated JSON body").WithCause(err)
+	}
+
+	// The messages of the json package mention Go types, describe the
+	// problem in JSON terms instead
+	msg := "invalid JSON body"
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		msg = fmt.Sprintf("invalid JSON body: syntax error at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		msg = fmt.Sprintf("invalid JSON body: field %q must be %s", typeErr.Field, jsonKind(typeErr.Type))
+	case errors.As(err, &typeErr):
+		msg = fmt.Sprintf("invalid JSON body: value must be %s", jsonKind(typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		msg = "invalid JSON body: unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	}
+	return NewHTTPError(http.StatusBadRequest, msg).WithCause(err)
+}
+
+// jsonKind describes the JSON values a Go type can be decoded from.
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return "a valid value"
+	}
+}
+
+// isJSONMediaType reports whether contentType is application/json or a +json
+// media type.
+func isJSONMediaType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == mimeJSON || (strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json"))
+}
+
+// validator is implemented by input types with a Validate method.
+type validator interface {
+	Validate() error
+}
+
+// validateInput calls the Validate method of the decoded input, looked up on
+// both the pointer to the value and the value itself so that pointer input
+// types are supported. Nil pointers are not validated.
+func validateInput(ptr, val any) error {
+	v, ok := ptr.(validator)
+	if !ok {
+		if v, ok = val.(validator); !ok {
+			return nil
+		}
+		if rv := reflect.ValueOf(val); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+	}
+
+	err := v.Validate()
+	if err == nil || HTTPStatus(err) != 0 {
+		return err
+	}
+	return NewHTTPError(http.StatusBadRequest, err.Error()).WithCause(err)
+}
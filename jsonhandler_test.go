@@ -0,0 +1,193 @@
+package webutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+type greetRequest struct {
+	Name string `json:"name"`
+}
+
+func (r greetRequest) Validate() error {
+	if r.Name == "nobody" {
+		return errors.New("name must not be nobody")
+	}
+	if r.Name == "admin" {
+		return webutil.StatusForbidden
+	}
+	return nil
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestJSONHandler(t *testing.T) {
+	h := webutil.JSON(func(ctx context.Context, req *http.Request, in greetRequest) (*greetResponse, error) {
+		if in.Name == "" {
+			in.Name = "world"
+		}
+		return &greetResponse{Greeting: "hello " + in.Name}, nil
+	})
+	h.MaxBodySize = 64
+	h.DisallowUnknownFields = true
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+		response    string
+	}{
+		{"Valid", "application/json", `{"name":"gopher"}`, http.StatusOK, `{"greeting":"hello gopher"}` + "\n"},
+		{"JSONSuffix", "application/merge-patch+json; charset=utf-8", `{"name":"gopher"}`, http.StatusOK, `{"greeting":"hello gopher"}` + "\n"},
+		{"NoBody", "", "", http.StatusOK, `{"greeting":"hello world"}` + "\n"},
+		{"WrongContentType", "text/plain", `{"name":"gopher"}`, http.StatusUnsupportedMediaType, ""},
+		{"TooLarge", "application/json", `{"name":"` + strings.Repeat("x", 100) + `"}`, http.StatusRequestEntityTooLarge, ""},
+		{"Malformed", "application/json", `{"name":`, http.StatusBadRequest, ""},
+		{"WrongType", "application/json", `{"name":42}`, http.StatusBadRequest, ""},
+		{"UnknownField", "application/json", `{"nick":"gopher"}`, http.StatusBadRequest, ""},
+		{"TrailingData", "application/json", `{"name":"a"} {}`, http.StatusBadRequest, ""},
+		{"ValidationError", "application/json", `{"name":"nobody"}`, http.StatusBadRequest, ""},
+		{"ValidationStatus", "application/json", `{"name":"admin"}`, http.StatusForbidden, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			webutil.Wrap(h).ServeHTTP(rec, req)
+
+			if rec.Code != tc.code {
+				t.Errorf("Status mismatch: got %d, want %d (body %q)", rec.Code, tc.code, rec.Body.String())
+			}
+			if tc.response != "" && rec.Body.String() != tc.response {
+				t.Errorf("Body mismatch: got %q, want %q", rec.Body.String(), tc.response)
+			}
+		})
+	}
+}
+
+type orderRequest struct {
+	Customer struct {
+		ID int64 `json:"id"`
+	} `json:"customer"`
+	Items []string `json:"items"`
+}
+
+func TestJSONHandlerDecodeErrors(t *testing.T) {
+	h := webutil.JSON(func(ctx context.Context, req *http.Request, in orderRequest) (*greetResponse, error) {
+		return &greetResponse{}, nil
+	})
+	h.DisallowUnknownFields = true
+
+	testCases := []struct {
+		name    string
+		body    string
+		message string
+		cause   any
+	}{
+		{"Syntax", `{"customer":}`, "invalid JSON body: syntax error at offset 13", new(*json.SyntaxError)},
+		{"NestedField", `{"customer":{"id":"42"}}`, `invalid JSON body: field "customer.id" must be an integer`, new(*json.UnmarshalTypeError)},
+		{"Root", `[]`, "invalid JSON body: value must be an object", new(*json.UnmarshalTypeError)},
+		{"UnknownField", `{"total":3}`, `invalid JSON body: unknown field "total"`, nil},
+		{"TrailingData", `{} {}`, "invalid JSON body: unexpected data after JSON value", nil},
+		{"Truncated", `{"items":[`, "truncated JSON body", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			err := h.ServeHTTP(httptest.NewRecorder(), req)
+
+			var statusErr *webutil.StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("ServeHTTP error: got %v, want *StatusError", err)
+			}
+			if statusErr.Code != http.StatusBadRequest {
+				t.Errorf("Status mismatch: got %d, want %d", statusErr.Code, http.StatusBadRequest)
+			}
+			if msg := statusErr.PublicMessage(); msg != tc.message {
+				t.Errorf("Message mismatch: got %q, want %q", msg, tc.message)
+			}
+			if tc.cause != nil && !errors.As(err, tc.cause) {
+				t.Errorf("Cause %T not found in %v", tc.cause, err)
+			}
+		})
+	}
+}
+
+func TestJSONHandlerStatus(t *testing.T) {
+	h := webutil.JSON(func(ctx context.Context, req *http.Request, in *greetRequest) (map[string]int, error) {
+		if in == nil {
+			return nil, webutil.StatusBadRequest
+		}
+		return map[string]int{"id": 1}, nil
+	})
+	h.Status = http.StatusCreated
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"gopher"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	if err := h.ServeHTTP(rec, req); err != nil {
+		t.Fatalf("ServeHTTP failed: %v", err)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type mismatch: got %q", ct)
+	}
+
+	// Errors from Func are returned without writing a response
+	rec = httptest.NewRecorder()
+	err := h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if !errors.Is(err, webutil.StatusBadRequest) {
+		t.Errorf("Error mismatch: got %v, want %v", err, webutil.StatusBadRequest)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Unexpected response body %q", rec.Body.String())
+	}
+}
+
+func TestJSONHandlerNoContent(t *testing.T) {
+	var called bool
+	h := webutil.JSON(func(ctx context.Context, req *http.Request, in greetRequest) (*greetResponse, error) {
+		called = true
+		return &greetResponse{Greeting: "ignored"}, nil
+	})
+	h.Status = http.StatusNoContent
+
+	srv := httptest.NewServer(webutil.Wrap(h))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"name":"gopher"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading body failed: %v", err)
+	}
+	if !called {
+		t.Errorf("Func was not called")
+	}
+	if resp.StatusCode != http.StatusNoContent || len(body) != 0 {
+		t.Errorf("Response mismatch: got %d %q, want 204 without body", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,55 @@
+package webutil
+
+import "net/http"
+
+// Middleware wraps a Handler to add behavior before or after it, such as
+// authentication, logging or rate limiting. Errors returned by the wrapped
+// Handler should be returned as is, or wrapped, so that they reach the
+// Wrapper serving them.
+//
+// Example:
+//
+//	func requireAuth(next webutil.Handler) webutil.Handler {
+//	    return webutil.HandlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+//	        if req.Header.Get("Authorization") == "" {
+//	            return webutil.StatusUnauthorized
+//	        }
+//	        return next.ServeHTTP(w, req)
+//	    })
+//	}
+type Middleware func(Handler) Handler
+
+// Chain composes middlewares into a single Middleware. The first middleware
+// is the outermost one, seeing requests first and errors last:
+//
+//	h := webutil.Chain(logging, requireAuth, rateLimit).Then(apiHandler)
+//	http.Handle("/api/", webutil.Wrap(h))
+func Chain(middlewares ...Middleware) Middleware {
+	return func(h Handler) Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+		return h
+	}
+}
+
+// Then applies the middleware to h, so that chains can be written as
+// webutil.Chain(a, b).Then(h). It is equivalent to m(h).
+func (m Middleware) Then(h Handler) Handler {
+	return m(h)
+}
+
+// AdaptMiddleware converts a standard net/http middleware into a Middleware.
+// The error returned by the inner Handler is propagated through the standard
+// middleware, as long as it calls its next handler synchronously.
+func AdaptMiddleware(mw func(http.Handler) http.Handler) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+			var err error
+			mw(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				err = next.ServeHTTP(w, req)
+			})).ServeHTTP(w, req)
+			return err
+		})
+	}
+}
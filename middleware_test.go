@@ -0,0 +1,54 @@
+package webutil_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+func TestChain(t *testing.T) {
+	var trace []string
+	tag := func(name string) webutil.Middleware {
+		return func(next webutil.Handler) webutil.Handler {
+			return webutil.HandlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+				trace = append(trace, name+" in")
+				err := next.ServeHTTP(w, req)
+				trace = append(trace, name+" out")
+				return err
+			})
+		}
+	}
+	stdHeader := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Std", "1")
+			next.ServeHTTP(w, req)
+		})
+	}
+
+	h := webutil.Chain(tag("a"), webutil.AdaptMiddleware(stdHeader), tag("b")).Then(
+		webutil.HandlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+			trace = append(trace, "handler")
+			return webutil.StatusTeapot
+		}))
+
+	err := h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(err, webutil.StatusTeapot) {
+		t.Errorf("Error mismatch: got %v, want %v", err, webutil.StatusTeapot)
+	}
+	if got := strings.Join(trace, ", "); got != "a in, b in, handler, b out, a out" {
+		t.Errorf("Call order mismatch: got %s", got)
+	}
+
+	// Errors propagate up to the Wrapper, which serves them
+	rec := serve(webutil.Wrap(h), "")
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusTeapot)
+	}
+	if rec.Header().Get("X-Std") != "1" {
+		t.Errorf("Standard middleware was not applied")
+	}
+}
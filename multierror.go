@@ -0,0 +1,208 @@
+package webutil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MultiStatus reports the individual results of a batch operation as a
+// 207 Multi-Status response, listing the status of every item.
+//
+// Errors holds one entry per item of the batch, nil for successful items.
+// Unlike errors.Join, which produces a single status for the whole batch,
+// MultiStatus always responds with 207 and keeps the position of each item.
+type MultiStatus struct {
+	Errors []error
+}
+
+// NewMultiStatus returns a MultiStatus for the given per-item results.
+func NewMultiStatus(errs ...error) *MultiStatus {
+	return &MultiStatus{Errors: errs}
+}
+
+// Error returns the messages of the failed items, one per line.
+func (e *MultiStatus) Error() string {
+	var msgs []string
+	for i, err := range e.Errors {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("item %d: %s", i, err))
+		}
+	}
+	if len(msgs) == 0 {
+		return "all items succeeded"
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// HTTPStatus returns http.StatusMultiStatus.
+func (e *MultiStatus) HTTPStatus() int {
+	return http.StatusMultiStatus
+}
+
+// Unwrap returns the errors of the failed items.
+func (e *MultiStatus) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ServeHTTP implements the http.Handler interface, rendering the per-item
+// results with the request's ErrorRenderer. Item messages are subject to
+// HideErrorDetails.
+func (e *MultiStatus) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	renderError(w, req, http.StatusMultiStatus, sanitizeError(w, req, http.StatusMultiStatus, e))
+}
+
+// errorItem describes one member of an aggregate error in rendered responses.
+type errorItem struct {
+	Code  int    `json:"code"`
+	Error string `json:"error,omitempty"`
+}
+
+// message returns the message of the item, the status text if it has none.
+func (i errorItem) message() string {
+	if i.Error == "" {
+		return statusText(i.Code)
+	}
+	return i.Error
+}
+
+// aggregateStatus returns the most severe status among errs: a status in a
+// higher class (5xx over 4xx over 3xx) wins, and the first one wins within
+// the same class. Members without a known status count as 500 as long as
+// at least one member has a status.
+func aggregateStatus(errs []error) int {
+	code, unknown := 0, false
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		c := HTTPStatus(err)
+		if c == 0 {
+			unknown = true
+			continue
+		}
+		if code == 0 || c/100 > code/100 {
+			code = c
+		}
+	}
+	if unknown && code != 0 && code/100 < 5 {
+		return http.StatusInternalServerError
+	}
+	return code
+}
+
+// errorMembers returns the members of err if err, or an error it wraps
+// through a chain of single errors, is an aggregate such as the result of
+// errors.Join. It returns nil if err is not an aggregate, or if an
+// http.Handler is found first in the chain.
+func errorMembers(err error) []error {
+	for err != nil {
+		switch e := err.(type) {
+		case *MultiStatus:
+			return e.Errors
+		case http.Handler:
+			return nil
+		case interface{ Unwrap() []error }:
+			return e.Unwrap()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// isAggregate reports whether err should be served as an aggregate error,
+// that is whether an errors.Join-style aggregate is found in its chain of
+// wrapped errors before any http.Handler.
+func isAggregate(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case http.Handler:
+			return false
+		case interface{ Unwrap() []error }:
+			return true
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// headerError is implemented by errors adding headers to their responses,
+// so that they are kept when the error is served as part of an aggregate.
+type headerError interface {
+	errorHeader() http.Header
+}
+
+// addMemberHeaders adds to h the headers of the members of an aggregate error
+// served with the given status. Members with a different status are ignored,
+// as their headers, such as challenges, would not apply to the response.
+func addMemberHeaders(h http.Header, members []error, code int) {
+	for _, err := range members {
+		if err == nil || memberStatus(err) != code {
+			continue
+		}
+		var he headerError
+		if !errors.As(err, &he) {
+			continue
+		}
+		for k, values := range he.errorHeader() {
+			for _, v := range values {
+				if !containsValue(h[k], v) {
+					h[k] = append(h[k], v)
+				}
+			}
+		}
+	}
+	if code == http.StatusUnauthorized && h.Get("WWW-Authenticate") == "" {
+		setDefaultChallenge(h)
+	}
+}
+
+// containsValue reports whether values contains v.
+func containsValue(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// memberStatus returns the status of a member of an aggregate error,
+// defaulting to 500.
+func memberStatus(err error) int {
+	if code := HTTPStatus(err); code != 0 {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// errorItems describes the members of an aggregate error for rendering.
+func errorItems(members []error) []errorItem {
+	items := make([]errorItem, len(members))
+	for i, err := range members {
+		if err == nil {
+			items[i] = errorItem{Code: http.StatusOK}
+			continue
+		}
+		code := memberStatus(err)
+		msg := errorMessage(err)
+		if msg == "" {
+			msg = statusText(code)
+		}
+		items[i] = errorItem{Code: code, Error: msg}
+	}
+	return items
+}
@@ -0,0 +1,103 @@
+package webutil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is a single media range parsed from an Accept header.
+type acceptRange struct {
+	typ, sub string  // media type and subtype, possibly "*"
+	q        float64 // quality value
+}
+
+// parseAccept parses the value of an Accept header into its media ranges.
+// Malformed entries are ignored.
+func parseAccept(accept string) []acceptRange {
+	var res []acceptRange
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		slash := strings.IndexByte(mt, '/')
+		if slash <= 0 || slash == len(mt)-1 {
+			continue
+		}
+
+		r := acceptRange{typ: mt[:slash], sub: mt[slash+1:], q: 1}
+		for _, p := range params[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(k), "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q >= 0 && q <= 1 {
+				r.q = q
+			}
+		}
+		res = append(res, r)
+	}
+
+	return res
+}
+
+// match returns how specifically the range matches the given media type:
+// 3 for an exact match, 2 for "type/*", 1 for "*/*" and 0 for no match.
+func (r acceptRange) match(typ, sub string) int {
+	switch {
+	case r.typ == "*" && r.sub == "*":
+		return 1
+	case r.typ != typ:
+		return 0
+	case r.sub == "*":
+		return 2
+	case r.sub == sub:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// NegotiateContentType returns the offer that best matches the Accept header
+// of the request, following the quality values and specificity rules of
+// RFC 9110 section 12.5.1.
+//
+// Offers are plain media types such as "application/json". When several offers
+// are equally acceptable, the most specifically matched one wins, then the one
+// listed first. If the request has no Accept header or none of the offers is
+// acceptable, the first offer is returned. An empty string is returned only
+// when no offers are given.
+func NegotiateContentType(req *http.Request, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	accept := req.Header.Values("Accept")
+	if len(accept) == 0 {
+		return offers[0]
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	best := offers[0]
+	bestQ, bestSpec := 0.0, 0
+
+	for _, offer := range offers {
+		typ, sub, ok := strings.Cut(strings.ToLower(offer), "/")
+		if !ok {
+			continue
+		}
+
+		// Use the quality of the most specific range matching this offer
+		q, spec := 0.0, 0
+		for _, r := range ranges {
+			if s := r.match(typ, sub); s > spec {
+				q, spec = r.q, s
+			}
+		}
+
+		if q > bestQ || (q == bestQ && q > 0 && spec > bestSpec) {
+			best, bestQ, bestSpec = offer, q, spec
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,65 @@
+package webutil
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// RecoverPanics enables panic recovery in every Wrapper and WrapFunc. It can
+// also be enabled for a single Wrapper through its Recover field.
+//
+// When enabled, a panic in a handler is reported to PanicLogger and answered
+// with a 500 error, served like any other error returned by the handler. If
+// the response had already started when the panic happened, it is handled
+// like other errors returned at that point (see ErrorTrailer). Panics with
+// http.ErrAbortHandler are never recovered.
+//
+// It should be set during program initialization, before any request is served.
+var RecoverPanics bool
+
+// PanicLogger is called with every panic recovered by a Wrapper or WrapFunc.
+// If nil, panics are logged with their stack trace using the standard log
+// package. Recovered panics are only reported there, never to ErrorLogger.
+var PanicLogger func(req *http.Request, err *PanicError)
+
+// PanicError is the error a recovered panic is converted into.
+type PanicError struct {
+	Value any    // Value passed to panic
+	Stack []byte // Stack trace of the goroutine at the time of the panic
+	ID    string // Correlation ID sent to the client as the X-Error-Id header
+}
+
+// Error returns a description of the panic value.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// HTTPStatus returns http.StatusInternalServerError.
+func (e *PanicError) HTTPStatus() int {
+	return http.StatusInternalServerError
+}
+
+// callRecover calls h, converting panics to a *PanicError.
+func callRecover(h func(http.ResponseWriter, *http.Request) error, w http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+		err = &PanicError{Value: v, Stack: debug.Stack()}
+	}()
+
+	return h(w, req)
+}
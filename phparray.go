@@ -0,0 +1,191 @@
+package webutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// PhpArray is an ordered array with the semantics of PHP arrays, as built by
+// ParsePhpQueryOrdered. Keys are strings, keys that are canonical decimal
+// integers (such as "0" or "-3", but not "05") being treated as integer keys
+// for the purpose of computing the next index used by Append.
+//
+// The zero value is an empty array ready to use.
+type PhpArray struct {
+	keys    []string
+	values  map[string]any
+	next    int64 // next free integer index, valid if hasNext
+	hasNext bool
+}
+
+// NewPhpArray returns an empty PhpArray.
+func NewPhpArray() *PhpArray {
+	return &PhpArray{}
+}
+
+// Len returns the number of elements in the array.
+func (a *PhpArray) Len() int {
+	return len(a.keys)
+}
+
+// Keys returns the keys of the array in insertion order.
+func (a *PhpArray) Keys() []string {
+	return append([]string(nil), a.keys...)
+}
+
+// Get returns the value stored at key, and whether it exists.
+func (a *PhpArray) Get(key string) (any, bool) {
+	v, ok := a.values[key]
+	return v, ok
+}
+
+// Set stores v at key. Existing keys keep their position, new keys are
+// added at the end of the array.
+func (a *PhpArray) Set(key string, v any) {
+	if a.values == nil {
+		a.values = make(map[string]any)
+	}
+	if _, ok := a.values[key]; !ok {
+		a.keys = append(a.keys, key)
+	}
+	a.values[key] = v
+
+	// Integer keys move the next free index past them, as in PHP 8.3
+	if n, ok := phpIntKey(key); ok && (!a.hasNext || n >= a.next) {
+		a.hasNext = true
+		a.next = n
+		if n < math.MaxInt64 {
+			a.next = n + 1
+		}
+	}
+}
+
+// delete removes key from the array. The next free index is not changed.
+func (a *PhpArray) delete(key string) {
+	if _, ok := a.values[key]; !ok {
+		return
+	}
+	delete(a.values, key)
+	for i, k := range a.keys {
+		if k == key {
+			a.keys = append(a.keys[:i], a.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Append stores v at the next free integer index, which is one more than the
+// largest integer key used so far, or 0. It returns false if that index is
+// already used, which only happens after math.MaxInt64 was used as key.
+func (a *PhpArray) Append(v any) bool {
+	var n int64
+	if a.hasNext {
+		n = a.next
+	}
+	key := strconv.FormatInt(n, 10)
+	if _, ok := a.values[key]; ok {
+		return false
+	}
+	a.Set(key, v)
+	return true
+}
+
+// IsList reports whether the keys of the array are the integers 0 to Len()-1
+// in order, like PHP's array_is_list.
+func (a *PhpArray) IsList() bool {
+	for i, k := range a.keys {
+		if k != strconv.Itoa(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Value converts the array to native Go values: lists become []any and other
+// arrays map[string]any, nested arrays being converted the same way.
+func (a *PhpArray) Value() any {
+	if a.IsList() {
+		list := make([]any, len(a.keys))
+		for i, k := range a.keys {
+			list[i] = phpNative(a.values[k])
+		}
+		return list
+	}
+	return a.Map()
+}
+
+// Map converts the array to a map, nested arrays being converted by Value.
+func (a *PhpArray) Map() map[string]any {
+	m := make(map[string]any, len(a.keys))
+	for _, k := range a.keys {
+		m[k] = phpNative(a.values[k])
+	}
+	return m
+}
+
+// MarshalJSON encodes lists as JSON arrays and other arrays as JSON objects
+// with members in the array's order, like PHP's json_encode.
+func (a *PhpArray) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	list := a.IsList()
+	if list {
+		buf.WriteByte('[')
+	} else {
+		buf.WriteByte('{')
+	}
+	for i, k := range a.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if !list {
+			key, _ := json.Marshal(k)
+			buf.Write(key)
+			buf.WriteByte(':')
+		}
+		val, err := json.Marshal(a.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	if list {
+		buf.WriteByte(']')
+	} else {
+		buf.WriteByte('}')
+	}
+	return buf.Bytes(), nil
+}
+
+// phpNative converts v to native Go values if it is a *PhpArray.
+func phpNative(v any) any {
+	if a, ok := v.(*PhpArray); ok {
+		return a.Value()
+	}
+	return v
+}
+
+// phpIntKey returns the integer value of key if PHP would treat it as an
+// integer array key: a decimal integer without leading zeros or plus sign
+// that fits in an int64.
+func phpIntKey(key string) (int64, bool) {
+	s := key
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" || (s[0] == '0' && (len(s) > 1 || len(key) > 1)) {
+		// Empty, leading zero or "-0"
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseInt(key, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
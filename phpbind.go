@@ -0,0 +1,469 @@
+package webutil
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidBindTarget is returned by BindPhpQuery and BindPhpMap when the
+// destination is not a non-nil pointer.
+var ErrInvalidBindTarget = errors.New("bind destination must be a non-nil pointer")
+
+// BindError reports a value that could not be stored in the destination of
+// BindPhpQuery or BindPhpMap. It is served as a 400 Bad Request error.
+type BindError struct {
+	Path string // PHP-style path of the value, such as "user[tags][2]"
+	Err  error  // Conversion error
+}
+
+// Error returns the path of the invalid value and the conversion error.
+func (e *BindError) Error() string {
+	return fmt.Sprintf("invalid value for %s: %v", e.Path, e.Err)
+}
+
+// PublicMessage returns the error message, which is safe to display to clients.
+func (e *BindError) PublicMessage() string {
+	return e.Error()
+}
+
+// Unwrap returns the conversion error.
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus returns 400 Bad Request.
+func (e *BindError) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// timeLayouts lists the formats accepted for time.Time values, in addition to
+// Unix timestamps.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// BindPhpQuery decodes PHP-style query values, as parsed by ConvertPhpQuery,
+// into the struct, map or slice pointed to by dst:
+//
+//	type filter struct {
+//	    Tags  []string       `php:"tags"`
+//	    Since time.Time      `php:"since"`
+//	    Page  int            `php:"page"`
+//	    Sort  map[string]int `php:"sort"`
+//	}
+//
+//	var f filter
+//	err := webutil.BindPhpQuery(req.URL.Query(), &f) // tags[]=a&tags[]=b&sort[name]=1
+//
+// See BindPhpMap for the conversion rules.
+func BindPhpQuery(values url.Values, dst any) error {
+	return BindPhpMap(ConvertPhpQuery(values), dst)
+}
+
+// Bind decodes values into dst like BindPhpQuery, returning a
+// *PhpQueryLimitError instead of ignoring variables when values exceed the
+// limits of the parser.
+func (p *PhpQueryParser) Bind(values url.Values, dst any) error {
+	result, err := p.ParseValues(values)
+	if err != nil {
+		return err
+	}
+	return BindPhpMap(result.Map(), dst)
+}
+
+// BindPhpMap decodes a structure returned by ParsePhpQuery or ConvertPhpQuery
+// into the value pointed to by dst.
+//
+// Struct fields are matched using the name in their `php` struct tag, or the
+// field name, first exactly then case-insensitively. Fields tagged "-" and
+// unexported fields are ignored, as are values without a matching field.
+// Embedded structs without a tag have their fields promoted.
+//
+// Strings are converted to the destination type: integers and floats using
+// strconv, booleans accepting 1/0, true/false, on/off and yes/no, durations
+// using time.ParseDuration and times as RFC 3339, date-only or Unix
+// timestamps, always returned in UTC. Types implementing
+// encoding.TextUnmarshaler decode themselves.
+// An empty string sets non-string values to their zero value.
+//
+// Slices and arrays accept PHP lists (a[]=x) and arrays with numeric keys
+// (a[0]=x), as well as a single value. Items are stored at their index, so
+// a[2]=x yields a slice of length 3 whose first two items are zero values.
+// Indices larger than 65535, or beyond the length of an array, are rejected.
+// Maps accept any keys convertible to the map's key type. Pointers are
+// allocated as needed, and values that are directly assignable to the
+// destination are stored as is.
+//
+// Conversion failures are returned as a *BindError.
+func BindPhpMap(data map[string]any, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ErrInvalidBindTarget
+	}
+	return bindPhpValue("", data, rv.Elem())
+}
+
+// bindPhpValue stores src in dst, path being the location of src in the input.
+func bindPhpValue(path string, src any, dst reflect.Value) error {
+	if src == nil {
+		return nil
+	}
+
+	// Values of the right type are stored directly
+	if sv := reflect.ValueOf(src); sv.Type().AssignableTo(dst.Type()) {
+		dst.Set(sv)
+		return nil
+	}
+
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return bindPhpValue(path, src, dst.Elem())
+	}
+
+	if s, ok := src.(string); ok {
+		if err := bindPhpString(s, dst); err != nil {
+			return &BindError{Path: path, Err: err}
+		}
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		m, ok := src.(map[string]any)
+		if !ok {
+			return &BindError{Path: path, Err: fmt.Errorf("expected an object, got %s", phpValueKind(src))}
+		}
+		return bindPhpStruct(path, m, dst)
+	case reflect.Slice, reflect.Array:
+		return bindPhpList(path, src, dst)
+	case reflect.Map:
+		return bindPhpMapValue(path, src, dst)
+	default:
+		return &BindError{Path: path, Err: fmt.Errorf("cannot store %s in %s", phpValueKind(src), dst.Type())}
+	}
+}
+
+// bindPhpString converts s to the type of dst.
+func bindPhpString(s string, dst reflect.Value) error {
+	if dst.Type() == timeType {
+		if s == "" {
+			dst.Set(reflect.Zero(timeType))
+			return nil
+		}
+		t, err := parsePhpTime(s)
+		if err == nil {
+			dst.Set(reflect.ValueOf(t))
+		}
+		return err
+	}
+	if dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshalerType) {
+		return dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if s == "" && dst.Kind() != reflect.String && dst.Kind() != reflect.Slice && dst.Kind() != reflect.Map {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(s)
+	case reflect.Bool:
+		b, err := parsePhpBool(s)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if dst.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			dst.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, dst.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, dst.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, dst.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		dst.SetFloat(f)
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes([]byte(s))
+			return nil
+		}
+		// A single value binds to a one-element slice
+		elem := reflect.New(dst.Type().Elem()).Elem()
+		if err := bindPhpString(s, elem); err != nil {
+			return err
+		}
+		dst.Set(reflect.Append(reflect.MakeSlice(dst.Type(), 0, 1), elem))
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return fmt.Errorf("cannot store a string in %s", dst.Type())
+		}
+		dst.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("cannot store a string in %s", dst.Type())
+	}
+	return nil
+}
+
+// bindPhpStruct stores the members of m in the fields of the struct dst.
+func bindPhpStruct(path string, m map[string]any, dst reflect.Value) error {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("php")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && !hasTag {
+			// Promote the fields of embedded structs
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fv := dst.Field(i)
+				if fv.Kind() == reflect.Pointer {
+					if fv.IsNil() {
+						if !fv.CanSet() {
+							continue
+						}
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				if err := bindPhpStruct(path, m, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		key, ok := lookupPhpKey(m, name)
+		if !ok {
+			continue
+		}
+		if err := bindPhpValue(phpPath(path, key), m[key], dst.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupPhpKey returns the key of m matching name, preferring an exact match
+// over a case-insensitive one.
+func lookupPhpKey(m map[string]any, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+
+	// Sort candidates so that the result does not depend on map order
+	var match string
+	found := false
+	for k := range m {
+		if strings.EqualFold(k, name) && (!found || k < match) {
+			match, found = k, true
+		}
+	}
+	return match, found
+}
+
+// maxPhpBindIndex is the largest index of a PHP array stored into a slice by
+// BindPhpMap, bounding the memory allocated for sparse indices.
+const maxPhpBindIndex = 1<<16 - 1
+
+// bindPhpList stores a PHP list or numerically indexed array in the slice or
+// array dst, each item at its index.
+func bindPhpList(path string, src any, dst reflect.Value) error {
+	var items []any
+	var indexes []int
+
+	switch v := src.(type) {
+	case []any:
+		items = v
+		for i := range v {
+			indexes = append(indexes, i)
+		}
+	case map[string]any:
+		// Numeric keys, in ascending order
+		for k := range v {
+			n, ok := phpIntKey(k)
+			if !ok || n < 0 {
+				return &BindError{Path: phpPath(path, k), Err: errors.New("expected a numeric index")}
+			}
+			if n > maxPhpBindIndex {
+				return &BindError{Path: phpPath(path, k), Err: fmt.Errorf("index out of range, at most %d allowed", maxPhpBindIndex)}
+			}
+			indexes = append(indexes, int(n))
+		}
+		sort.Ints(indexes)
+		for _, n := range indexes {
+			items = append(items, v[strconv.Itoa(n)])
+		}
+	default:
+		return &BindError{Path: path, Err: fmt.Errorf("expected a list, got %s", phpValueKind(src))}
+	}
+
+	size := 0
+	if len(indexes) > 0 {
+		size = indexes[len(indexes)-1] + 1
+	}
+
+	list := dst
+	if dst.Kind() == reflect.Array {
+		if size > dst.Len() {
+			k := strconv.Itoa(indexes[len(indexes)-1])
+			return &BindError{Path: phpPath(path, k), Err: fmt.Errorf("index out of range, at most %d values allowed", dst.Len())}
+		}
+		dst.Set(reflect.Zero(dst.Type()))
+	} else {
+		list = reflect.MakeSlice(dst.Type(), size, size)
+	}
+
+	for i, item := range items {
+		n := indexes[i]
+		if err := bindPhpValue(phpPath(path, strconv.Itoa(n)), item, list.Index(n)); err != nil {
+			return err
+		}
+	}
+	if dst.Kind() == reflect.Slice {
+		dst.Set(list)
+	}
+	return nil
+}
+
+// bindPhpMapValue stores the members of a PHP array in the map dst.
+func bindPhpMapValue(path string, src any, dst reflect.Value) error {
+	var m map[string]any
+	switch v := src.(type) {
+	case map[string]any:
+		m = v
+	case []any:
+		m = make(map[string]any, len(v))
+		for i, item := range v {
+			m[strconv.Itoa(i)] = item
+		}
+	default:
+		return &BindError{Path: path, Err: fmt.Errorf("expected an object, got %s", phpValueKind(src))}
+	}
+
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+	}
+
+	// Bind in key order so that the reported error is deterministic
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kt, vt := dst.Type().Key(), dst.Type().Elem()
+	for _, k := range keys {
+		kv := reflect.New(kt).Elem()
+		if err := bindPhpString(k, kv); err != nil {
+			return &BindError{Path: phpPath(path, k), Err: fmt.Errorf("invalid key: %w", err)}
+		}
+		ev := reflect.New(vt).Elem()
+		if err := bindPhpValue(phpPath(path, k), m[k], ev); err != nil {
+			return err
+		}
+		dst.SetMapIndex(kv, ev)
+	}
+	return nil
+}
+
+// parsePhpBool parses the boolean representations commonly sent by forms.
+func parsePhpBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "on", "yes":
+		return true, nil
+	case "0", "false", "off", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
+
+// parsePhpTime parses a time in one of timeLayouts, or a Unix timestamp. The
+// result is in UTC regardless of the offset in s or the local time zone.
+func parsePhpTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0).UTC(), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// numError strips the function name and input from strconv errors, keeping
+// messages such as "value out of range".
+func numError(err error) error {
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		return fmt.Errorf("%w %q", ne.Err, ne.Num)
+	}
+	return err
+}
+
+// phpPath appends key to a PHP-style path.
+func phpPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "[" + key + "]"
+}
+
+// phpValueKind describes a parsed PHP value for error messages.
+func phpValueKind(v any) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
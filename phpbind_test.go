@@ -0,0 +1,185 @@
+package webutil_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+type bindAddress struct {
+	City string `php:"city"`
+	Zip  int    `php:"zip"`
+}
+
+type bindBase struct {
+	ID uint64 `php:"id"`
+}
+
+type bindUser struct {
+	bindBase
+	Name     string            `php:"name"`
+	Age      int8              `php:"age"`
+	Admin    bool              `php:"admin"`
+	Score    float64           `php:"score"`
+	Born     time.Time         `php:"born"`
+	Timeout  time.Duration     `php:"timeout"`
+	IP       net.IP            `php:"ip"`
+	Tags     []string          `php:"tags"`
+	Scores   []int             `php:"scores"`
+	Address  *bindAddress      `php:"address"`
+	Contacts []bindAddress     `php:"contacts"`
+	Meta     map[string]string `php:"meta"`
+	Counts   map[int]int       `php:"counts"`
+	Extra    any               `php:"extra"`
+	Secret   string            `php:"-"`
+	Nickname string
+}
+
+func TestBindPhpQuery(t *testing.T) {
+	values, _ := url.ParseQuery("id=7&name=gopher&age=12&admin=on&score=1.5&born=2009-11-10" +
+		"&timeout=1m30s&ip=127.0.0.1&tags[]=a&tags[]=b&scores[1]=20&scores[0]=10" +
+		"&address[city]=Paris&address[zip]=75001&contacts[][city]=Lyon&meta[k]=v" +
+		"&counts[3]=9&extra[x]=y&Secret=s&NICKNAME=gophy")
+
+	var u bindUser
+	if err := webutil.BindPhpQuery(values, &u); err != nil {
+		t.Fatalf("BindPhpQuery failed: %v", err)
+	}
+
+	want := bindUser{
+		bindBase: bindBase{ID: 7},
+		Name:     "gopher",
+		Age:      12,
+		Admin:    true,
+		Score:    1.5,
+		Born:     time.Date(2009, 11, 10, 0, 0, 0, 0, time.UTC),
+		Timeout:  90 * time.Second,
+		IP:       net.ParseIP("127.0.0.1"),
+		Tags:     []string{"a", "b"},
+		Scores:   []int{10, 20},
+		Address:  &bindAddress{City: "Paris", Zip: 75001},
+		Contacts: []bindAddress{{City: "Lyon"}},
+		Meta:     map[string]string{"k": "v"},
+		Counts:   map[int]int{3: 9},
+		Extra:    map[string]any{"x": "y"},
+		Nickname: "gophy",
+	}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("Bind mismatch:\n got %+v\nwant %+v", u, want)
+	}
+}
+
+func TestBindPhpTime(t *testing.T) {
+	want := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name  string
+		value string
+	}{
+		{"Timestamp", "1257894000"},
+		{"UTC", "2009-11-10T23:00:00Z"},
+		{"Offset", "2009-11-11T01:00:00+02:00"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var dst struct {
+				At time.Time `php:"at"`
+			}
+			if err := webutil.BindPhpQuery(url.Values{"at": {tc.value}}, &dst); err != nil {
+				t.Fatalf("BindPhpQuery failed: %v", err)
+			}
+			if dst.At != want {
+				t.Errorf("Time mismatch: got %v, want %v", dst.At, want)
+			}
+		})
+	}
+}
+
+func TestBindPhpQueryIndices(t *testing.T) {
+	type lists struct {
+		Slice []string  `php:"s"`
+		Array [3]string `php:"a"`
+	}
+
+	testCases := []struct {
+		name  string
+		query string
+		want  lists
+		path  string // path of the BindError, if any
+	}{
+		{"Sparse", "s[2]=x&a[2]=x", lists{Slice: []string{"", "", "x"}, Array: [3]string{"", "", "x"}}, ""},
+		{"Unordered", "s[1]=b&s[0]=a&a[1]=b&a[0]=a", lists{Slice: []string{"a", "b"}, Array: [3]string{"a", "b", ""}}, ""},
+		{"Append", "s[5]=x&s[]=y", lists{Slice: []string{"", "", "", "", "", "x", "y"}}, ""},
+		{"ArrayOutOfRange", "a[3]=x", lists{}, "a[3]"},
+		{"SliceOutOfRange", "s[100000]=x", lists{}, "s[100000]"},
+		{"LeadingZero", "s[01]=x", lists{}, "s[01]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tc.query)
+			var got lists
+			err := webutil.BindPhpQuery(values, &got)
+			if tc.path != "" {
+				var berr *webutil.BindError
+				if !errors.As(err, &berr) || berr.Path != tc.path {
+					t.Fatalf("Expected a BindError for %s, got %v", tc.path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BindPhpQuery failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Result mismatch:\ngot:  %#v\nwant: %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBindPhpQueryErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		path  string
+	}{
+		{"Int", "age=abc", "age"},
+		{"Overflow", "age=300", "age"},
+		{"Bool", "admin=maybe", "admin"},
+		{"Time", "born=yesterday", "born"},
+		{"NestedInt", "address[zip]=x", "address[zip]"},
+		{"ListItem", "scores[]=1&scores[]=x", "scores[1]"},
+		{"ListIndex", "scores[a]=1", "scores[a]"},
+		{"ObjectExpected", "address=Paris", "address"},
+		{"MapKey", "counts[x]=1", "counts[x]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tc.query)
+			var u bindUser
+			err := webutil.BindPhpQuery(values, &u)
+
+			var berr *webutil.BindError
+			if !errors.As(err, &berr) {
+				t.Fatalf("Expected a BindError, got %v", err)
+			}
+			if berr.Path != tc.path {
+				t.Errorf("Path mismatch: got %q, want %q", berr.Path, tc.path)
+			}
+			if code := webutil.HTTPStatus(err); code != http.StatusBadRequest {
+				t.Errorf("HTTPStatus: got %d, want %d", code, http.StatusBadRequest)
+			}
+		})
+	}
+
+	if err := webutil.BindPhpQuery(nil, bindUser{}); !errors.Is(err, webutil.ErrInvalidBindTarget) {
+		t.Errorf("Non-pointer destination: got %v, want ErrInvalidBindTarget", err)
+	}
+}
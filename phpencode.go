@@ -0,0 +1,268 @@
+package webutil
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PhpQueryEncoding selects how BuildPhpQuery percent-encodes keys and values.
+type PhpQueryEncoding int
+
+const (
+	// PhpQueryRFC1738 encodes spaces as "+", like PHP's urlencode and the
+	// default PHP_QUERY_RFC1738 mode of http_build_query.
+	PhpQueryRFC1738 PhpQueryEncoding = iota
+
+	// PhpQueryRFC3986 encodes spaces as "%20" and leaves "~" unescaped, like
+	// PHP's rawurlencode and the PHP_QUERY_RFC3986 mode of http_build_query.
+	PhpQueryRFC3986
+)
+
+// BuildPhpQuery generates a query string from data in the same way as PHP's
+// http_build_query.
+//
+// The data can be a map, a *PhpArray, a struct or a pointer to one of these.
+// Maps are encoded in sorted key order, integer keys first, and PhpArray
+// values in their own order, so that the output is deterministic. Nested
+// values use bracket notation with the brackets percent-encoded, lists and
+// arrays using numeric indices (a%5B0%5D=x&a%5B1%5D=y). Booleans are encoded
+// as 1 and 0, floats like PHP with 14 significant digits, nil values and empty
+// arrays are skipped, and types implementing encoding.TextMarshaler encode
+// themselves.
+//
+// Struct fields are named after their `php` struct tag, or the field name.
+// Fields tagged "-" and unexported fields are skipped, as are fields with the
+// omitempty option holding a zero value. Embedded structs without a tag have
+// their fields promoted.
+func BuildPhpQuery(data any, enc PhpQueryEncoding) string {
+	return string(appendPhpQuery(nil, "", reflect.ValueOf(data), enc))
+}
+
+// appendPhpQuery appends the encoded pairs for v to buf. The prefix is the
+// already encoded key of v, empty for the top-level value.
+func appendPhpQuery(buf []byte, prefix string, v reflect.Value, enc PhpQueryEncoding) []byte {
+	if !v.IsValid() || ((v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()) {
+		return buf
+	}
+
+	// Values reached through unexported embedded structs cannot be
+	// converted to interfaces, and are encoded based on their kind only
+	if v.CanInterface() {
+		switch val := v.Interface().(type) {
+		case *PhpArray:
+			for _, k := range val.keys {
+				buf = appendPhpQuery(buf, phpQueryKey(prefix, k, enc), reflect.ValueOf(val.values[k]), enc)
+			}
+			return buf
+		case []byte:
+			return appendPhpPair(buf, prefix, string(val), enc)
+		case *bytes.Buffer:
+			return appendPhpPair(buf, prefix, val.String(), enc)
+		case encoding.TextMarshaler:
+			text, err := val.MarshalText()
+			if err != nil {
+				return buf
+			}
+			return appendPhpPair(buf, prefix, string(text), enc)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return appendPhpPair(buf, prefix, v.String(), enc)
+	case reflect.Bool:
+		if v.Bool() {
+			return appendPhpPair(buf, prefix, "1", enc)
+		}
+		return appendPhpPair(buf, prefix, "0", enc)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendPhpPair(buf, prefix, strconv.FormatInt(v.Int(), 10), enc)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendPhpPair(buf, prefix, strconv.FormatUint(v.Uint(), 10), enc)
+	case reflect.Float32, reflect.Float64:
+		return appendPhpPair(buf, prefix, phpFloatString(v.Float()), enc)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			buf = appendPhpQuery(buf, phpQueryKey(prefix, strconv.Itoa(i), enc), v.Index(i), enc)
+		}
+		return buf
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := phpMapKey(iter.Key())
+			keys = append(keys, k)
+			values[k] = iter.Value()
+		}
+		sortPhpKeys(keys)
+		for _, k := range keys {
+			buf = appendPhpQuery(buf, phpQueryKey(prefix, k, enc), values[k], enc)
+		}
+		return buf
+	case reflect.Pointer, reflect.Interface:
+		return appendPhpQuery(buf, prefix, v.Elem(), enc)
+	case reflect.Struct:
+		return appendPhpStruct(buf, prefix, v, enc)
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return buf
+	default:
+		if !v.CanInterface() {
+			return buf
+		}
+		return appendPhpPair(buf, prefix, fmt.Sprint(v.Interface()), enc)
+	}
+}
+
+// appendPhpStruct appends the encoded pairs for the fields of the struct v.
+func appendPhpStruct(buf []byte, prefix string, v reflect.Value, enc PhpQueryEncoding) []byte {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("php")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if f.Anonymous && !hasTag {
+			// Promote the fields of embedded structs
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				buf = appendPhpStruct(buf, prefix, fv, enc)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if opts == "omitempty" && fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		buf = appendPhpQuery(buf, phpQueryKey(prefix, name, enc), fv, enc)
+	}
+	return buf
+}
+
+// appendPhpPair appends a key=value pair, key being already encoded.
+func appendPhpPair(buf []byte, key, value string, enc PhpQueryEncoding) []byte {
+	if key == "" {
+		// Scalars cannot be encoded without a key
+		return buf
+	}
+	if len(buf) > 0 {
+		buf = append(buf, '&')
+	}
+	buf = append(buf, key...)
+	buf = append(buf, '=')
+	return appendPhpURLEncode(buf, value, enc)
+}
+
+// phpQueryKey returns the encoded key of the member k of the value whose
+// encoded key is prefix.
+func phpQueryKey(prefix, k string, enc PhpQueryEncoding) string {
+	if prefix == "" {
+		return string(appendPhpURLEncode(nil, k, enc))
+	}
+	return prefix + "%5B" + string(appendPhpURLEncode(nil, k, enc)) + "%5D"
+}
+
+// appendPhpURLEncode appends s to buf encoded like PHP's urlencode, or
+// rawurlencode in PhpQueryRFC3986 mode.
+func appendPhpURLEncode(buf []byte, s string, enc PhpQueryEncoding) []byte {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '.', c == '_':
+			buf = append(buf, c)
+		case c == '~' && enc == PhpQueryRFC3986:
+			buf = append(buf, c)
+		case c == ' ' && enc == PhpQueryRFC1738:
+			buf = append(buf, '+')
+		default:
+			buf = append(buf, '%', hex[c>>4], hex[c&15])
+		}
+	}
+	return buf
+}
+
+// phpFloatString formats f like PHP's %.14G conversion used by
+// http_build_query.
+func phpFloatString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NAN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+
+	s := strconv.FormatFloat(f, 'g', 14, 64)
+	mant, exp, ok := strings.Cut(s, "e")
+	if !ok {
+		if strings.Contains(s, ".") {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+		return s
+	}
+
+	// PHP keeps a decimal point in the mantissa and does not pad the exponent
+	if strings.Contains(mant, ".") {
+		mant = strings.TrimRight(strings.TrimRight(mant, "0"), ".")
+	}
+	if !strings.Contains(mant, ".") {
+		mant += ".0"
+	}
+	sign := exp[:1]
+	exp = strings.TrimLeft(exp[1:], "0")
+	return mant + "E" + sign + exp
+}
+
+// sortPhpKeys sorts map keys, integer keys coming first in numeric order.
+func sortPhpKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, aInt := phpIntKey(keys[i])
+		b, bInt := phpIntKey(keys[j])
+		if aInt != bInt {
+			return aInt
+		}
+		if aInt {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+// phpMapKey returns the string form of a map key.
+func phpMapKey(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	if k.CanInterface() {
+		return fmt.Sprint(k.Interface())
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package webutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+)
+
+// DefaultMaxFormMemory is the maximum amount of multipart data ParsePhpForm
+// keeps in memory, the remainder of uploaded files being stored on disk.
+const DefaultMaxFormMemory = 32 << 20 // 32MB
+
+// ParsePhpForm parses the body of an application/x-www-form-urlencoded or
+// multipart/form-data request into the nested structure returned by
+// ParsePhpQuery, like PHP's $_POST and $_FILES combined.
+//
+// Uploaded files are stored as *multipart.FileHeader leaves at the position
+// given by their field name, so that "files[]" yields a list of files and
+// "user[avatar]" a file in the "user" object, next to the other form values.
+// Uploads are kept in memory up to DefaultMaxFormMemory or maxSize, whichever
+// is smaller, and temporary files must be removed by calling RemoveAll on the
+// request's MultipartForm once done.
+//
+// Bodies larger than maxSize are rejected with StatusRequestEntityTooLarge,
+// forms exceeding the default limits of PhpQueryParser with a
+// *PhpQueryLimitError, malformed bodies with StatusBadRequest and other
+// content types with StatusUnsupportedMediaType. Requests without a body
+// return an empty map. The query string of the request is not included.
+//
+// Use PhpQueryParser.ParseForm to configure the limits.
+func ParsePhpForm(req *http.Request, maxSize int64) (map[string]any, error) {
+	return defaultPhpQueryParser.ParseForm(req, maxSize)
+}
+
+// ParseForm parses the body of a form request like ParsePhpForm, enforcing
+// the limits of the parser.
+func (p *PhpQueryParser) ParseForm(req *http.Request, maxSize int64) (map[string]any, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return make(map[string]any), nil
+	}
+
+	mt, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil && req.Header.Get("Content-Type") != "" {
+		return nil, NewHTTPError(http.StatusBadRequest, "invalid Content-Type").WithCause(err)
+	}
+
+	body := http.MaxBytesReader(nil, req.Body, maxSize)
+
+	switch mt {
+	case "application/x-www-form-urlencoded":
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, formReadError(err)
+		}
+		result, err := p.Parse(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return result.Map(), nil
+	case "multipart/form-data":
+		boundary := params["boundary"]
+		if boundary == "" {
+			return nil, NewHTTPError(http.StatusBadRequest, "missing multipart boundary")
+		}
+
+		maxMemory := int64(DefaultMaxFormMemory)
+		if maxSize < maxMemory {
+			maxMemory = maxSize
+		}
+		form, err := multipart.NewReader(body, boundary).ReadForm(maxMemory)
+		if err != nil {
+			return nil, formReadError(err)
+		}
+		req.MultipartForm = form
+
+		// Keys are processed in order so that the result is deterministic
+		b := p.newBuilder(true)
+		for _, key := range sortedKeys(form.Value) {
+			for _, val := range form.Value[key] {
+				if err := b.add(key, val); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, key := range sortedKeys(form.File) {
+			for _, fh := range form.File[key] {
+				if err := b.add(key, fh); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return b.result.Map(), nil
+	default:
+		return nil, NewHTTPError(http.StatusUnsupportedMediaType, "request body must be a form")
+	}
+}
+
+// formReadError converts an error returned while reading a form body to the
+// matching HTTP error.
+func formReadError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit)).WithCause(err)
+	}
+	if errors.Is(err, multipart.ErrMessageTooLarge) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, "form data too large").WithCause(err)
+	}
+	return NewHTTPError(http.StatusBadRequest, "invalid form data").WithCause(err)
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
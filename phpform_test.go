@@ -0,0 +1,103 @@
+package webutil_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+func TestParsePhpForm(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("user[name]", "gopher")
+	_ = mw.WriteField("tags[]", "a")
+	_ = mw.WriteField("tags[]", "b")
+	for _, f := range []struct{ field, name, content string }{
+		{"user[avatar]", "avatar.png", "PNG"},
+		{"files[]", "a.txt", "first"},
+		{"files[]", "b.txt", "second"},
+	} {
+		fw, _ := mw.CreateFormFile(f.field, f.name)
+		_, _ = fw.Write([]byte(f.content))
+	}
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	form, err := webutil.ParsePhpForm(req, 1<<20)
+	if err != nil {
+		t.Fatalf("ParsePhpForm failed: %v", err)
+	}
+	defer req.MultipartForm.RemoveAll()
+
+	user, _ := form["user"].(map[string]any)
+	if user["name"] != "gopher" {
+		t.Errorf("user[name] mismatch: got %v", user["name"])
+	}
+	if avatar, ok := user["avatar"].(*multipart.FileHeader); !ok || avatar.Filename != "avatar.png" {
+		t.Errorf("user[avatar] mismatch: got %#v", user["avatar"])
+	}
+	if tags, _ := form["tags"].([]any); len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("tags mismatch: got %v", form["tags"])
+	}
+	files, _ := form["files"].([]any)
+	if len(files) != 2 {
+		t.Fatalf("files mismatch: got %v", form["files"])
+	}
+	for i, name := range []string{"a.txt", "b.txt"} {
+		if fh, ok := files[i].(*multipart.FileHeader); !ok || fh.Filename != name {
+			t.Errorf("files[%d] mismatch: got %#v", i, files[i])
+		}
+	}
+
+	// Files bind to FileHeader fields
+	var dst struct {
+		User struct {
+			Avatar *multipart.FileHeader `php:"avatar"`
+		} `php:"user"`
+		Files []*multipart.FileHeader `php:"files"`
+	}
+	if err := webutil.BindPhpMap(form, &dst); err != nil {
+		t.Fatalf("BindPhpMap failed: %v", err)
+	}
+	if dst.User.Avatar == nil || len(dst.Files) != 2 {
+		t.Errorf("Bind mismatch: got %+v", dst)
+	}
+}
+
+func TestParsePhpFormErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+	}{
+		{"URLEncoded", "application/x-www-form-urlencoded", "a[b]=c", 0},
+		{"TooLarge", "application/x-www-form-urlencoded", "a=" + strings.Repeat("x", 100), http.StatusRequestEntityTooLarge},
+		{"WrongType", "application/json", "{}", http.StatusUnsupportedMediaType},
+		{"NoBoundary", "multipart/form-data", "--x--", http.StatusBadRequest},
+		{"Malformed", "multipart/form-data; boundary=x", "garbage", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			form, err := webutil.ParsePhpForm(req, 64)
+
+			if code := webutil.HTTPStatus(err); code != tc.code {
+				t.Errorf("Status mismatch: got %d, want %d (%v)", code, tc.code, err)
+			}
+			if tc.code == 0 {
+				if a, _ := form["a"].(map[string]any); a["b"] != "c" {
+					t.Errorf("Form mismatch: got %v", form)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package webutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Default limits of PhpQueryParser, matching PHP's default max_input_vars and
+// max_input_nesting_level settings.
+const (
+	DefaultPhpMaxInputVars    = 1000
+	DefaultPhpMaxNestingLevel = 64
+)
+
+// Names of the limits reported by PhpQueryLimitError.
+const (
+	PhpLimitInputVars    = "max_input_vars"
+	PhpLimitNestingLevel = "max_input_nesting_level"
+	PhpLimitLength       = "max_length"
+)
+
+var (
+	defaultPhpQueryParser = &PhpQueryParser{}
+	compatPhpQueryParser  = &PhpQueryParser{Compat: true}
+)
+
+// PhpQueryParser parses PHP-style query strings with configurable resource
+// limits, protecting against queries with huge numbers of variables or deeply
+// nested arrays.
+//
+// The zero value parses like ParsePhpQueryOrdered with PHP's default limits,
+// but returns a *PhpQueryLimitError instead of silently ignoring variables
+// when a limit is exceeded.
+type PhpQueryParser struct {
+	// Compat parses variable names exactly like PHP's parse_str, see ParsePhpStr
+	Compat bool
+
+	// MaxInputVars limits the number of variables, DefaultPhpMaxInputVars
+	// if zero. A negative value disables the limit.
+	MaxInputVars int
+
+	// MaxNestingLevel limits the number of brackets in variable names,
+	// DefaultPhpMaxNestingLevel if zero. A negative value disables the limit.
+	MaxNestingLevel int
+
+	// MaxLength limits the length of the query string in bytes, no limit if
+	// zero or negative.
+	MaxLength int
+}
+
+// PhpQueryLimitError is returned by PhpQueryParser when a query exceeds one of
+// its limits. It is served as 413 Request Entity Too Large when the query has
+// too many variables or is too long, and as 400 Bad Request when variables are
+// nested too deeply.
+type PhpQueryLimitError struct {
+	Limit string // PhpLimitInputVars, PhpLimitNestingLevel or PhpLimitLength
+	Max   int    // Value of the exceeded limit
+}
+
+// Error describes the exceeded limit.
+func (e *PhpQueryLimitError) Error() string {
+	return fmt.Sprintf("query exceeds %s of %d", e.Limit, e.Max)
+}
+
+// PublicMessage returns the error message, which is safe to display to clients.
+func (e *PhpQueryLimitError) PublicMessage() string {
+	return e.Error()
+}
+
+// HTTPStatus returns the HTTP status code for the exceeded limit.
+func (e *PhpQueryLimitError) HTTPStatus() int {
+	if e.Limit == PhpLimitNestingLevel {
+		return http.StatusBadRequest
+	}
+	return http.StatusRequestEntityTooLarge
+}
+
+// Parse parses a query string into an ordered PhpArray.
+func (p *PhpQueryParser) Parse(query string) (*PhpArray, error) {
+	return p.parse(query, true)
+}
+
+// ParseValues parses url.Values, processing keys in sorted order.
+func (p *PhpQueryParser) ParseValues(values url.Values) (*PhpArray, error) {
+	b := p.newBuilder(true)
+	for _, key := range sortedKeys(values) {
+		for _, val := range values[key] {
+			if err := b.add(key, val); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.result, nil
+}
+
+// parse parses query. If strict is false, limits are enforced the way PHP
+// does: variables past the limit or nested too deeply are ignored.
+func (p *PhpQueryParser) parse(query string, strict bool) (*PhpArray, error) {
+	if p.MaxLength > 0 && len(query) > p.MaxLength {
+		if strict {
+			return nil, &PhpQueryLimitError{Limit: PhpLimitLength, Max: p.MaxLength}
+		}
+		query = query[:p.MaxLength]
+	}
+
+	b := p.newBuilder(strict)
+	for query != "" {
+		// Split incrementally, so that huge queries do not allocate every part
+		var part string
+		part, query, _ = strings.Cut(query, "&")
+		if part == "" {
+			continue
+		}
+
+		var key, value string
+		if p.Compat {
+			key, value, _ = strings.Cut(part, "=")
+			key, value = phpURLDecode(key), phpURLDecode(value)
+		} else {
+			var ok bool
+			if key, value, ok = splitPhpQueryPair(part); !ok {
+				// Malformed pairs still count as input variables
+				key = ""
+			}
+		}
+
+		if err := b.add(key, value); err != nil {
+			return nil, err
+		}
+		if b.done {
+			break
+		}
+	}
+
+	return b.result, nil
+}
+
+// phpQueryBuilder adds variables to a PhpArray while enforcing the limits of
+// a PhpQueryParser.
+type phpQueryBuilder struct {
+	parser *PhpQueryParser
+	result *PhpArray
+	strict bool // return limit errors instead of ignoring variables
+	count  int  // number of variables added
+	done   bool // the variable limit was reached
+}
+
+// newBuilder returns a phpQueryBuilder adding variables to a new PhpArray.
+func (p *PhpQueryParser) newBuilder(strict bool) *phpQueryBuilder {
+	return &phpQueryBuilder{parser: p, result: NewPhpArray(), strict: strict}
+}
+
+// add adds the variable key with the given value, which is a string or a
+// *multipart.FileHeader. Variables with an empty key count against the limit
+// but are otherwise ignored.
+func (b *phpQueryBuilder) add(key string, value any) error {
+	if b.done {
+		return nil
+	}
+
+	b.count++
+	if max := phpLimit(b.parser.MaxInputVars, DefaultPhpMaxInputVars); max > 0 && b.count > max {
+		if b.strict {
+			return &PhpQueryLimitError{Limit: PhpLimitInputVars, Max: max}
+		}
+		b.done = true
+		return nil
+	}
+	if key == "" {
+		return nil
+	}
+
+	var path []string
+	var levels int
+	if b.parser.Compat {
+		path, levels = parsePhpStrKey(key)
+	} else {
+		path = phpQueryPath(key)
+		levels = len(path) - 1
+	}
+	if path == nil {
+		return nil
+	}
+
+	if max := phpLimit(b.parser.MaxNestingLevel, DefaultPhpMaxNestingLevel); max > 0 && levels > max {
+		if b.strict {
+			return &PhpQueryLimitError{Limit: PhpLimitNestingLevel, Max: max}
+		}
+		if b.parser.Compat {
+			// PHP discards the whole variable, including earlier values
+			b.result.delete(path[0])
+		}
+		return nil
+	}
+
+	processPhpArrayPath(b.result, path, value)
+	return nil
+}
+
+// phpLimit returns the effective value of a limit setting, 0 meaning no limit.
+func phpLimit(v, def int) int {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return 0
+	default:
+		return v
+	}
+}
@@ -1,8 +1,6 @@
 package webutil
 
 import (
-	"bytes"
-	"fmt"
 	"net/url"
 	"strings"
 )
@@ -16,83 +14,61 @@ import (
 //   - a[b][]=c (multi-level nesting)
 //   - a[][][]=c (multi-level arrays)
 //
-// The resulting map contains properly structured nested objects and arrays.
+// Indices follow PHP semantics: a[]=x appends at the index following the
+// largest integer key, so a[5]=x&a[]=y stores y at index 6. Arrays whose keys
+// are 0 to n-1 in order, such as those built by a[]=x&a[]=y or a[0]=x&a[1]=y,
+// are returned as []any, other arrays as map[string]any. Use
+// ParsePhpQueryOrdered to preserve the order of keys.
 func ParsePhpQuery(query string) map[string]any {
-	result := make(map[string]any)
-
-	// Split query string on '&' and process each part
-	for _, part := range strings.Split(query, "&") {
-		if part != "" {
-			parsePhpQ(result, part)
-		}
-	}
+	return ParsePhpQueryOrdered(query).Map()
+}
 
-	// Process the result to clean up any pointer references
-	parsePhpFix(result)
+// ParsePhpQueryOrdered parses a PHP-compatible query string like
+// ParsePhpQuery, but returns ordered PhpArray values preserving the order in
+// which keys appear in the query string.
+//
+// Like PHP, only the first DefaultPhpMaxInputVars variables are parsed, and
+// variables nested deeper than DefaultPhpMaxNestingLevel are ignored. Use a
+// PhpQueryParser to configure these limits or report them as errors.
+func ParsePhpQueryOrdered(query string) *PhpArray {
+	result, _ := defaultPhpQueryParser.parse(query, false)
 	return result
 }
 
 // ConvertPhpQuery converts standard url.Values to a structured map
 // using PHP-style array/object notation in the keys.
+//
+// Since url.Values does not record the order of keys, keys are processed in
+// sorted order. The limits of ParsePhpQueryOrdered apply.
 func ConvertPhpQuery(values url.Values) map[string]any {
-	result := make(map[string]any)
+	b := defaultPhpQueryParser.newBuilder(false)
 
 	// Process each key-value pair
-	for key, vals := range values {
-		for _, val := range vals {
-			parsePhpQV(result, val, key)
+	for _, key := range sortedKeys(values) {
+		for _, val := range values[key] {
+			_ = b.add(key, val)
 		}
 	}
 
-	// Clean up the structure
-	parsePhpFix(result)
-	return result
+	return b.result.Map()
 }
 
-// parsePhpFix recursively processes the parsed query structure,
-// resolving pointer references and ensuring proper nesting.
-func parsePhpFix(value any) any {
-	switch val := value.(type) {
-	case map[string]any:
-		// Process each key-value pair in the map
-		for k, v := range val {
-			val[k] = parsePhpFix(v)
-		}
-		return val
-	case []any:
-		// Process each item in the array
-		for i, v := range val {
-			val[i] = parsePhpFix(v)
-		}
-		return val
-	case *[]any:
-		// Dereference and process
-		return parsePhpFix(*val)
-	default:
-		// Other types are returned as-is
-		return value
-	}
-}
-
-// parsePhpQ parses a single key-value part from a query string.
-func parsePhpQ(result map[string]any, part string) {
-	if part == "" {
-		return
-	}
-
+// splitPhpQueryPair splits and decodes a key=value part of a query string. It
+// returns false if the key is empty or either part is malformed.
+func splitPhpQueryPair(part string) (string, string, bool) {
 	// Split into key and value
 	var key, value string
 	if eqIdx := strings.IndexByte(part, '='); eqIdx != -1 {
 		if eqIdx == 0 {
 			// Ignore if key is empty
-			return
+			return "", "", false
 		}
 		// URL-decode the value
 		var err error
 		value, err = url.QueryUnescape(part[eqIdx+1:])
 		if err != nil {
 			// Skip malformed values
-			return
+			return "", "", false
 		}
 		key = part[:eqIdx]
 	} else {
@@ -104,41 +80,36 @@ func parsePhpQ(result map[string]any, part string) {
 	decodedKey, err := url.QueryUnescape(key)
 	if err != nil {
 		// Skip malformed keys
-		return
+		return "", "", false
 	}
 
-	// Process the key-value pair
-	parsePhpQV(result, value, decodedKey)
+	return decodedKey, value, true
 }
 
-// parsePhpQV processes a key-value pair, handling PHP-style array/object notation in the key.
-func parsePhpQV(result map[string]any, value, key string) {
+// phpQueryPath splits a key using PHP-style array/object notation into its
+// path components, an empty component standing for []. It returns nil if the
+// key is malformed.
+func phpQueryPath(key string) []string {
 	// Find the first bracket, which indicates array/object syntax
 	bracketIdx := strings.IndexByte(key, '[')
 	if bracketIdx == -1 {
 		// Simple key-value, no brackets
-		result[key] = value
-		return
+		return []string{key}
 	}
 	if bracketIdx == 0 {
 		// Key can't start with a bracket
-		return
+		return nil
 	}
 
 	// Extract the base key and parse the array/object path
-	baseName := key[:bracketIdx]
 	path := parsePhpArrayPath(key[bracketIdx:])
 	if len(path) == 0 {
 		// Malformed path
-		return
+		return nil
 	}
 
 	// Start with the base name
-	currentPath := []string{baseName}
-	currentPath = append(currentPath, path...)
-
-	// Process the path
-	processPhpArrayPath(result, currentPath, value)
+	return append([]string{key[:bracketIdx]}, path...)
 }
 
 // parsePhpArrayPath extracts the path components from PHP array/object notation.
@@ -177,114 +148,45 @@ func parsePhpArrayPath(pathStr string) []string {
 	return path
 }
 
-// processPhpArrayPath builds the nested structure according to the path components.
-func processPhpArrayPath(result map[string]any, path []string, value string) {
+// processPhpArrayPath builds the nested structure according to the path
+// components, an empty component appending a new element like PHP's [].
+func processPhpArrayPath(result *PhpArray, path []string, value any) {
 	if len(path) == 0 {
 		return
 	}
 
-	// Extract current and next path components
-	current := path[0]
-	remaining := path[1:]
-
-	if len(remaining) == 0 {
-		// We're at the leaf node, set the value
-		result[current] = value
-		return
-	}
-
-	// Handle based on the next path component
-	next := remaining[0]
-	isArray := next == ""
-
-	if isArray {
-		// Next component is array notation "[]"
-		var arr *[]any
-
-		// Try to get existing array or create new one
-		if existingArr, ok := result[current].(*[]any); ok {
-			arr = existingArr
-		} else {
-			newArr := make([]any, 0)
-			arr = &newArr
-			result[current] = arr
-		}
-
-		if len(remaining) == 1 {
-			// Last component is array, append value
-			*arr = append(*arr, value)
-		} else {
-			// More components after array, create nested structure
-			newMap := make(map[string]any)
-			*arr = append(*arr, newMap)
-			processPhpArrayPath(newMap, remaining[1:], value)
+	// Walk down to the array holding the leaf, creating arrays as needed
+	current := result
+	for _, key := range path[:len(path)-1] {
+		if key == "" {
+			// Array notation "[]" always creates a new element
+			next := NewPhpArray()
+			if !current.Append(next) {
+				return
+			}
+			current = next
+			continue
 		}
-	} else {
-		// Next component is named key, creating/updating a map
-		var nestedMap map[string]any
 
-		// Try to get existing map or create new one
-		if existing, ok := result[current].(map[string]any); ok {
-			nestedMap = existing
-		} else {
-			nestedMap = make(map[string]any)
-			result[current] = nestedMap
+		// Named or numeric key, existing non-array values are replaced
+		next, ok := current.values[key].(*PhpArray)
+		if !ok {
+			next = NewPhpArray()
+			current.Set(key, next)
 		}
-
-		// Process the rest of the path in the nested map
-		processPhpArrayPath(nestedMap, remaining, value)
+		current = next
 	}
-}
-
-// EncodePhpQuery converts a structured map back to a PHP-compatible query string.
-func EncodePhpQuery(query map[string]any) string {
-	var result []byte
 
-	// Process each top-level key
-	for key, value := range query {
-		result = encodePhpQueryAppend(result, value, key)
+	// Set the leaf value
+	if leaf := path[len(path)-1]; leaf == "" {
+		current.Append(value)
+	} else {
+		current.Set(leaf, value)
 	}
-
-	return string(result)
 }
 
-// encodePhpQueryAppend recursively builds a query string by appending
-// encoded key-value pairs to an existing byte slice.
-func encodePhpQueryAppend(result []byte, value any, key string) []byte {
-	switch val := value.(type) {
-	case map[string]any:
-		// Handle nested maps as PHP objects
-		for subKey, subValue := range val {
-			result = encodePhpQueryAppend(result, subValue, key+"["+subKey+"]")
-		}
-
-	case []any:
-		// Handle arrays with [] notation
-		for _, subValue := range val {
-			result = encodePhpQueryAppend(result, subValue, key+"[]")
-		}
-
-	case string:
-		// Handle string values with proper encoding
-		if len(result) > 0 {
-			result = append(result, '&')
-		}
-		result = append(result, url.QueryEscape(key)...)
-		result = append(result, '=')
-		result = append(result, url.QueryEscape(val)...)
-
-	case []byte:
-		// Convert byte slices to strings
-		return encodePhpQueryAppend(result, string(val), key)
-
-	case *bytes.Buffer:
-		// Convert buffers to strings
-		return encodePhpQueryAppend(result, val.String(), key)
-
-	default:
-		// Convert anything else to string representation
-		return encodePhpQueryAppend(result, fmt.Sprintf("%+v", val), key)
-	}
-
-	return result
+// EncodePhpQuery converts a structured map back to a PHP-compatible query
+// string, like BuildPhpQuery with PhpQueryRFC1738 encoding.
+func EncodePhpQuery(query map[string]any) string {
+	return BuildPhpQuery(query, PhpQueryRFC1738)
 }
@@ -0,0 +1,396 @@
+package webutil_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+func TestParsePhpQuery(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		want  map[string]any
+	}{
+		{"Simple", "a=b&c=d", map[string]any{"a": "b", "c": "d"}},
+		{"Object", "a[b]=c&a[d]=e", map[string]any{"a": map[string]any{"b": "c", "d": "e"}}},
+		{"List", "a[]=x&a[]=y", map[string]any{"a": []any{"x", "y"}}},
+		{"DenseIndices", "a[0]=x&a[1]=y", map[string]any{"a": []any{"x", "y"}}},
+		{"SparseIndices", "a[0]=x&a[5]=y", map[string]any{"a": map[string]any{"0": "x", "5": "y"}}},
+		{"NextIndex", "a[5]=x&a[]=y", map[string]any{"a": map[string]any{"5": "x", "6": "y"}}},
+		{"MixedIndices", "a[]=x&a[1]=y&a[]=z", map[string]any{"a": []any{"x", "y", "z"}}},
+		{"NegativeIndex", "a[-3]=x&a[]=y", map[string]any{"a": map[string]any{"-3": "x", "-2": "y"}}},
+		{"StringIndex", "a[x]=1&a[]=2", map[string]any{"a": map[string]any{"x": "1", "0": "2"}}},
+		{"LeadingZero", "a[05]=x&a[]=y", map[string]any{"a": map[string]any{"05": "x", "0": "y"}}},
+		{"Overwrite", "a[0]=x&a[0]=y", map[string]any{"a": []any{"y"}}},
+		{"OutOfOrder", "a[1]=y&a[0]=x", map[string]any{"a": map[string]any{"0": "x", "1": "y"}}},
+		{"ListOfObjects", "a[][b]=1&a[][b]=2", map[string]any{"a": []any{map[string]any{"b": "1"}, map[string]any{"b": "2"}}}},
+		{"NestedLists", "a[][]=1&a[][]=2", map[string]any{"a": []any{[]any{"1"}, []any{"2"}}}},
+		{"ReplaceScalar", "a=1&a[b]=2", map[string]any{"a": map[string]any{"b": "2"}}},
+		{"MaxIndex", "a[9223372036854775807]=x&a[]=y", map[string]any{"a": map[string]any{"9223372036854775807": "x"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := webutil.ParsePhpQuery(tc.query); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParsePhpQuery(%q) = %#v, want %#v", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParsePhpQueryOrdered(t *testing.T) {
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{"z=1&a=2&m[y]=3&m[b]=4", `{"z":"1","a":"2","m":{"y":"3","b":"4"}}`},
+		{"a[2]=x&a[]=y&a[0]=z", `{"a":{"2":"x","3":"y","0":"z"}}`},
+		{"a[]=x&a[]=y", `{"a":["x","y"]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := json.Marshal(webutil.ParsePhpQueryOrdered(tc.query))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("Got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+type encodeBase struct {
+	ID int `php:"id"`
+}
+
+type encodeUser struct {
+	encodeBase
+	Name   string    `php:"name"`
+	Tags   []string  `php:"tags"`
+	Admin  bool      `php:"admin"`
+	Born   time.Time `php:"born"`
+	Note   string    `php:"note,omitempty"`
+	Secret string    `php:"-"`
+	Parent *encodeUser
+}
+
+func TestBuildPhpQuery(t *testing.T) {
+	testCases := []struct {
+		name string
+		data any
+		enc  webutil.PhpQueryEncoding
+		want string
+	}{
+		{"SortedKeys", map[string]any{"b": "2", "a": "1", "c": "3"}, webutil.PhpQueryRFC1738, "a=1&b=2&c=3"},
+		{"IntKeysFirst", map[string]any{"x": "a", "10": "b", "2": "c"}, webutil.PhpQueryRFC1738, "2=c&10=b&x=a"},
+		{"List", map[string]any{"a": []any{"x", "y"}}, webutil.PhpQueryRFC1738, "a%5B0%5D=x&a%5B1%5D=y"},
+		{"Nested", map[string]any{"a": map[string]any{"b": []string{"c"}}}, webutil.PhpQueryRFC1738, "a%5Bb%5D%5B0%5D=c"},
+		{"Scalars", map[string]any{"t": true, "f": false, "n": nil, "i": -4, "u": uint8(7), "e": []any{}}, webutil.PhpQueryRFC1738, "f=0&i=-4&t=1&u=7"},
+		{"Floats", map[string]any{"a": 1.5, "b": 2.0, "c": 1e20, "d": 0.00001, "e": 1.0 / 3}, webutil.PhpQueryRFC1738, "a=1.5&b=2&c=1.0E%2B20&d=1.0E-5&e=0.33333333333333"},
+		{"RFC1738", map[string]any{"k y": "a b~c"}, webutil.PhpQueryRFC1738, "k+y=a+b%7Ec"},
+		{"RFC3986", map[string]any{"k y": "a b~c"}, webutil.PhpQueryRFC3986, "k%20y=a%20b~c"},
+		{"Ordered", webutil.ParsePhpQueryOrdered("z=1&a[x]=2&a[]=3"), webutil.PhpQueryRFC1738, "z=1&a%5Bx%5D=2&a%5B0%5D=3"},
+		{"Struct", &encodeUser{
+			encodeBase: encodeBase{ID: 3},
+			Name:       "gopher",
+			Tags:       []string{"a"},
+			Born:       time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC),
+			Secret:     "s",
+			Parent:     &encodeUser{Name: "root", Note: "n"},
+		}, webutil.PhpQueryRFC1738, "id=3&name=gopher&tags%5B0%5D=a&admin=0&born=2009-11-10T23%3A00%3A00Z" +
+			"&Parent%5Bid%5D=0&Parent%5Bname%5D=root&Parent%5Badmin%5D=0&Parent%5Bborn%5D=0001-01-01T00%3A00%3A00Z&Parent%5Bnote%5D=n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := webutil.BuildPhpQuery(tc.data, tc.enc); got != tc.want {
+				t.Errorf("BuildPhpQuery() =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+
+	// EncodePhpQuery output parses back to the same structure
+	query := map[string]any{"a": []any{"x", "y"}, "b": map[string]any{"c": "d e"}}
+	if got := webutil.ParsePhpQuery(webutil.EncodePhpQuery(query)); !reflect.DeepEqual(got, query) {
+		t.Errorf("Round-trip mismatch: got %#v", got)
+	}
+}
+
+// parseStrCases holds queries exercising the key mangling and index rules of
+// PHP's parse_str, with the result expected from PHP 8.3 as encoded by
+// json_encode. The results follow main/php_variables.c and are checked against
+// PHP itself by TestParsePhpStrPHP when it is available.
+var parseStrCases = []struct {
+	query string
+	want  string
+}{
+	{"a=1&b=2", `{"a":"1","b":"2"}`},
+	{"a.b=1", `{"a_b":"1"}`},
+	{"a b=1", `{"a_b":"1"}`},
+	{"%20a=1", `{"a":"1"}`},
+	{"+a=1", `{"a":"1"}`},
+	{"a+b=1", `{"a_b":"1"}`},
+	{"a.b[c.d]=1", `{"a_b":{"c.d":"1"}}`},
+	{"a[b=1", `{"a_b":"1"}`},
+	{"a[b.c=1", `{"a_b_c":"1"}`},
+	{"a[b[c=1", `{"a_b_c":"1"}`},
+	{"a[b]c=1", `{"a":{"b":"1"}}`},
+	{"a[b]c[d]=1", `{"a":{"b":"1"}}`},
+	{"a[b][c=1", `{"a":{"b":"1"}}`},
+	{"a[b[c]]=1", `{"a":{"b[c":"1"}}`},
+	{"[a]=1", `[]`},
+	{"=1", `[]`},
+	{" =1", `[]`},
+	{"&&a=1&&", `{"a":"1"}`},
+	{"a", `{"a":""}`},
+	{"a=", `{"a":""}`},
+	{"a=1=2", `{"a":"1=2"}`},
+	{"a=%zz&b=%4", `{"a":"%zz","b":"%4"}`},
+	{"a%00b=1", `{"a":"1"}`},
+	{"a=b%00c", `{"a":"b\u0000c"}`},
+	{"a[]=1&a[]=2", `{"a":["1","2"]}`},
+	{"a[5]=1&a[]=2", `{"a":{"5":"1","6":"2"}}`},
+	{"a[-3]=1&a[]=2", `{"a":{"-3":"1","-2":"2"}}`},
+	{"a[05]=1&a[]=2", `{"a":{"05":"1","0":"2"}}`},
+	{"a[-0]=1&a[]=2", `{"a":{"-0":"1","0":"2"}}`},
+	{"a[%2B1]=1&a[]=2", `{"a":{"+1":"1","0":"2"}}`},
+	{"a[1]=1&a[0]=2", `{"a":{"1":"1","0":"2"}}`},
+	{"a[]=1&a[]=2&a[0]=3", `{"a":["3","2"]}`},
+	{"a[]=1&a[]=2&a[3]=3&a[]=4", `{"a":{"0":"1","1":"2","3":"3","4":"4"}}`},
+	{"a[9223372036854775807]=1&a[]=2", `{"a":{"9223372036854775807":"1"}}`},
+	{"a=1&a[b]=2", `{"a":{"b":"2"}}`},
+	{"a[b]=2&a=1", `{"a":"1"}`},
+	{"a[b]=1&a[b]=2", `{"a":{"b":"2"}}`},
+	{"a[x]=1&a[x][y]=2", `{"a":{"x":{"y":"2"}}}`},
+	{"a[][b]=1&a[][b]=2", `{"a":[{"b":"1"},{"b":"2"}]}`},
+	{"a[0][]=1&a[0][]=2", `{"a":[["1","2"]]}`},
+	{"a[][=1", `{"a":["1"]}`},
+	{"a.[b]=1", `{"a_":{"b":"1"}}`},
+	{"a.b[=1", `{"a_b_":"1"}`},
+	{"a[.]=1", `{"a":{".":"1"}}`},
+	{"a[ ]=1", `{"a":["1"]}`},
+	{"a[b][ ]=1", `{"a":{"b":["1"]}}`},
+	{"a[ b]=1", `{"a":{" b":"1"}}`},
+	{"a[  ]=1", `{"a":{"  ":"1"}}`},
+	{"a[b c]=1", `{"a":{"b c":"1"}}`},
+	{"a]=1", `{"a]":"1"}`},
+	{"a[b]]=1", `{"a":{"b":"1"}}`},
+	{"a%5Bb%5D=1", `{"a":{"b":"1"}}`},
+	{"a[b][]", `{"a":{"b":[""]}}`},
+	{"a=1&A=2", `{"a":"1","A":"2"}`},
+	{"é[ü]=ß", `{"é":{"ü":"ß"}}`},
+}
+
+// TestParsePhpStr checks ParsePhpStr against the expected results of
+// parseStrCases.
+func TestParsePhpStr(t *testing.T) {
+	for _, tc := range parseStrCases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := json.Marshal(webutil.ParsePhpStr(tc.query))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			// Compare token streams, which preserve key order but not escaping
+			if !reflect.DeepEqual(jsonTokens(t, got), jsonTokens(t, []byte(tc.want))) {
+				t.Errorf("ParsePhpStr(%q) = %s, want %s", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestParsePhpStrPHP runs the queries of parseStrCases through PHP's
+// parse_str and compares the results with ParsePhpStr and the expected ones.
+// It is skipped unless PHP 8.3 or later is found in the PATH.
+func TestParsePhpStrPHP(t *testing.T) {
+	php, err := exec.LookPath("php")
+	if err != nil {
+		t.Skip("php not found in PATH")
+	}
+	version, err := exec.Command(php, "-r", "echo PHP_VERSION_ID;").Output()
+	if err != nil {
+		t.Fatalf("Failed to get PHP version: %v", err)
+	}
+	if id, _ := strconv.Atoi(string(version)); id < 80300 {
+		t.Skipf("PHP 8.3 or later required, found %s", version)
+	}
+
+	var queries strings.Builder
+	for _, tc := range parseStrCases {
+		queries.WriteString(tc.query + "\n")
+	}
+	cmd := exec.Command(php, "testdata/parse_str.php")
+	cmd.Stdin = strings.NewReader(queries.String())
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("parse_str.php failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != len(parseStrCases) {
+		t.Fatalf("PHP produced %d results for %d queries", len(lines), len(parseStrCases))
+	}
+	for i, tc := range parseStrCases {
+		var entry struct {
+			Query  string          `json:"query"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatalf("Invalid PHP output %q: %v", lines[i], err)
+		}
+		if entry.Query != tc.query {
+			t.Fatalf("PHP result %d is for %q, want %q", i, entry.Query, tc.query)
+		}
+
+		want := jsonTokens(t, entry.Result)
+		got, err := json.Marshal(webutil.ParsePhpStr(tc.query))
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if !reflect.DeepEqual(jsonTokens(t, got), want) {
+			t.Errorf("ParsePhpStr(%q) = %s, PHP %s gives %s", tc.query, got, version, entry.Result)
+		}
+		if !reflect.DeepEqual(jsonTokens(t, []byte(tc.want)), want) {
+			t.Errorf("Expected result for %q is %s, PHP %s gives %s", tc.query, tc.want, version, entry.Result)
+		}
+	}
+}
+
+// jsonTokens returns the tokens of a JSON document.
+func jsonTokens(t *testing.T, data []byte) []json.Token {
+	t.Helper()
+	var tokens []json.Token
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return tokens
+		}
+		if err != nil {
+			t.Fatalf("Invalid JSON %s: %v", data, err)
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+func TestPhpQueryParserLimits(t *testing.T) {
+	deep := "a" + strings.Repeat("[]", 65) + "=1"
+
+	testCases := []struct {
+		name   string
+		parser webutil.PhpQueryParser
+		query  string
+		limit  string
+		status int
+	}{
+		{"InputVars", webutil.PhpQueryParser{MaxInputVars: 2}, "a=1&b=2&c=3", webutil.PhpLimitInputVars, http.StatusRequestEntityTooLarge},
+		{"DefaultInputVars", webutil.PhpQueryParser{}, strings.Repeat("a[]=1&", 1001), webutil.PhpLimitInputVars, http.StatusRequestEntityTooLarge},
+		{"NestingLevel", webutil.PhpQueryParser{MaxNestingLevel: 2}, "a[b][c][d]=1", webutil.PhpLimitNestingLevel, http.StatusBadRequest},
+		{"DefaultNestingLevel", webutil.PhpQueryParser{}, deep, webutil.PhpLimitNestingLevel, http.StatusBadRequest},
+		{"CompatNestingLevel", webutil.PhpQueryParser{Compat: true}, deep, webutil.PhpLimitNestingLevel, http.StatusBadRequest},
+		{"Length", webutil.PhpQueryParser{MaxLength: 8}, "a=123456789", webutil.PhpLimitLength, http.StatusRequestEntityTooLarge},
+		{"WithinLimits", webutil.PhpQueryParser{MaxInputVars: 3, MaxNestingLevel: 3}, "a=1&b[c][d][e]=2&c=3", "", 0},
+		{"Unlimited", webutil.PhpQueryParser{MaxInputVars: -1, MaxNestingLevel: -1}, deep + strings.Repeat("&a=1", 1000), "", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.parser.Parse(tc.query)
+			if tc.limit == "" {
+				if err != nil {
+					t.Fatalf("Parse failed: %v", err)
+				}
+				return
+			}
+
+			var lerr *webutil.PhpQueryLimitError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("Expected a PhpQueryLimitError, got %v", err)
+			}
+			if lerr.Limit != tc.limit {
+				t.Errorf("Limit mismatch: got %q, want %q", lerr.Limit, tc.limit)
+			}
+			if code := webutil.HTTPStatus(err); code != tc.status {
+				t.Errorf("HTTPStatus: got %d, want %d", code, tc.status)
+			}
+		})
+	}
+
+	// Package functions ignore variables past the limits like PHP
+	if got := webutil.ParsePhpQuery(strings.Repeat("a[]=1&", 1500)); len(got["a"].([]any)) != webutil.DefaultPhpMaxInputVars {
+		t.Errorf("Input vars were not truncated: got %d", len(got["a"].([]any)))
+	}
+	if got := webutil.ParsePhpQuery("b=1&" + deep); !reflect.DeepEqual(got, map[string]any{"b": "1"}) {
+		t.Errorf("Deep variable was not ignored: got %v", got)
+	}
+	if got := webutil.ParsePhpStr("a[x]=1&b=2&" + deep).Map(); !reflect.DeepEqual(got, map[string]any{"b": "2"}) {
+		t.Errorf("PHP mode must remove the whole variable: got %v", got)
+	}
+}
+
+func TestPhpQueryParserForm(t *testing.T) {
+	p := &webutil.PhpQueryParser{MaxInputVars: 2}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, v := range []string{"a", "b", "c"} {
+		_ = mw.WriteField("tags[]", v)
+	}
+	_ = mw.Close()
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"URLEncoded", "application/x-www-form-urlencoded", "tags[]=a&tags[]=b&tags[]=c"},
+		{"Multipart", mw.FormDataContentType(), buf.String()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			_, err := p.ParseForm(req, 1<<20)
+			var lerr *webutil.PhpQueryLimitError
+			if !errors.As(err, &lerr) || lerr.Limit != webutil.PhpLimitInputVars || lerr.Max != 2 {
+				t.Errorf("Expected a max_input_vars error of 2, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPhpQueryParserBind(t *testing.T) {
+	p := &webutil.PhpQueryParser{MaxNestingLevel: 1}
+
+	var dst struct {
+		Tags []string `php:"tags"`
+	}
+	if err := p.Bind(url.Values{"tags[]": {"a", "b"}}, &dst); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if !reflect.DeepEqual(dst.Tags, []string{"a", "b"}) {
+		t.Errorf("Tags mismatch: got %v", dst.Tags)
+	}
+
+	err := p.Bind(url.Values{"tags[][x]": {"a"}}, &dst)
+	var lerr *webutil.PhpQueryLimitError
+	if !errors.As(err, &lerr) || lerr.Limit != webutil.PhpLimitNestingLevel {
+		t.Errorf("Expected a max_input_nesting_level error, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package webutil
+
+import (
+	"strings"
+)
+
+// ParsePhpStr parses a query string exactly like PHP's parse_str, returning
+// the variables as an ordered PhpArray.
+//
+// Unlike ParsePhpQuery, which rejects malformed keys, it reproduces PHP's
+// handling of variable names:
+//   - leading spaces are removed, and spaces and dots before the first
+//     bracket are replaced with underscores ("a.b" is "a_b")
+//   - an opening bracket without a matching closing bracket is replaced with
+//     an underscore along with any following space, dot or bracket ("a[b.c"
+//     is "a_b_c"), or ends the name when it follows a complete index
+//   - anything after a closing bracket that is not followed by an opening
+//     bracket is ignored ("a[b]c" is "a[b]")
+//   - "[ ]" with a single space is the same as "[]"
+//   - names are truncated at the first NUL byte, and names that end up empty
+//     are ignored
+//
+// Percent-encoding is decoded leniently like PHP's urldecode: invalid
+// sequences are kept as is instead of discarding the pair. The limits of
+// ParsePhpQueryOrdered apply, variables nested too deeply being removed
+// entirely like in PHP.
+func ParsePhpStr(query string) *PhpArray {
+	result, _ := compatPhpQueryParser.parse(query, false)
+	return result
+}
+
+// parsePhpStrKey splits a decoded variable name into its path components the
+// same way as PHP's php_register_variable_ex, an empty component standing for
+// []. It returns nil if the variable must be ignored, and the nesting level of
+// the name as counted by PHP.
+func parsePhpStrKey(name string) ([]string, int) {
+	if i := strings.IndexByte(name, 0); i != -1 {
+		name = name[:i]
+	}
+	name = strings.TrimLeft(name, " ")
+
+	// Spaces and dots are not allowed in the base name
+	bracket := strings.IndexByte(name, '[')
+	if bracket == -1 {
+		bracket = len(name)
+	}
+	base := strings.NewReplacer(" ", "_", ".", "_").Replace(name[:bracket])
+	if base == "" {
+		return nil, 0
+	}
+
+	path := []string{base}
+	levels := 0
+	rest := name[bracket:]
+	for rest != "" {
+		// rest starts with '['
+		levels++
+		if strings.HasPrefix(rest, "[ ]") {
+			// PHP skips a single space before looking for the closing
+			// bracket, so "[ ]" is the same as "[]"
+			path = append(path, "")
+			rest = rest[3:]
+			if !strings.HasPrefix(rest, "[") {
+				break
+			}
+			continue
+		}
+
+		end := strings.IndexByte(rest, ']')
+		if end == -1 {
+			if len(path) == 1 {
+				// Not an index, the bracket becomes part of the name
+				tail := strings.NewReplacer(" ", "_", ".", "_", "[", "_").Replace(rest[1:])
+				return []string{base + "_" + tail}, levels
+			}
+			// The previous index is the last one
+			return path, levels
+		}
+
+		path = append(path, rest[1:end])
+		rest = rest[end+1:]
+		if !strings.HasPrefix(rest, "[") {
+			// Anything else after an index is ignored
+			break
+		}
+	}
+
+	return path, levels
+}
+
+// phpURLDecode decodes s like PHP's urldecode: "+" becomes a space and
+// percent-encoded bytes are decoded, invalid sequences being kept as is.
+func phpURLDecode(s string) string {
+	if strings.IndexByte(s, '%') == -1 && strings.IndexByte(s, '+') == -1 {
+		return s
+	}
+
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '+':
+			buf = append(buf, ' ')
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			buf = append(buf, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(buf)
+}
+
+// isHex reports whether c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of the hexadecimal digit c.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
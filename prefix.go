@@ -30,14 +30,20 @@ type AddPrefix struct {
 // and then passes the modified request to the underlying handler.
 func (h *SkipPrefix) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// trim prefix from request
-	r.URL.Path = strings.TrimPrefix(r.URL.Path, h.Prefix)
-	r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, h.Prefix)
-	r.RequestURI = strings.TrimPrefix(r.RequestURI, h.Prefix)
-	r.Header.Set("Sec-Access-Prefix", h.Prefix)
+	skipPrefix(r, h.Prefix)
 	// and serve
 	h.Handler.ServeHTTP(w, r)
 }
 
+// skipPrefix removes prefix from the request URL path, RawPath and
+// RequestURI, and sets the Sec-Access-Prefix header to the removed prefix.
+func skipPrefix(r *http.Request, prefix string) {
+	r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+	r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+	r.RequestURI = strings.TrimPrefix(r.RequestURI, prefix)
+	r.Header.Set("Sec-Access-Prefix", prefix)
+}
+
 // pathJoin joins path segments, handling slashes appropriately.
 // It ensures that there are no double slashes between segments.
 // Unlike path.Join, it does not clean the path and preserves trailing slashes.
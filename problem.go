@@ -0,0 +1,222 @@
+package webutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrNotProblem is returned by ReadProblem when the response does not carry
+// an application/problem+json body.
+var ErrNotProblem = errors.New("response is not a problem details document")
+
+// maxProblemSize is the maximum size of a problem details body read by ReadProblem.
+const maxProblemSize = 64 << 10 // 64KB
+
+// Problem represents an RFC 9457 problem details object.
+//
+// It implements the error and http.Handler interfaces, and provides an
+// HTTPStatus method so that HTTPStatus and ServeError handle it like any
+// other HTTP error. The StandardErrorRenderer serves a Problem as
+// application/problem+json unless the client asks for another format.
+type Problem struct {
+	Type     string // URI reference identifying the problem type, "about:blank" if empty
+	Title    string // Short human-readable summary of the problem type
+	Status   int    // HTTP status code, 500 if zero
+	Detail   string // Human-readable explanation specific to this occurrence
+	Instance string // URI reference identifying this occurrence
+
+	// Extensions holds additional members of the problem details object.
+	// Keys colliding with the standard members are ignored when encoding.
+	Extensions map[string]any
+
+	// Err is the underlying cause of the problem. It is never sent to
+	// clients, but is logged like errors hidden by HideErrorDetails if the
+	// status is 5xx.
+	Err error
+}
+
+// NewProblem creates a Problem for the given HTTP status code and detail
+// message. The title defaults to the standard status text.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Status: status,
+		Title:  statusText(status),
+		Detail: detail,
+	}
+}
+
+// Error implements the error interface, returning the title and detail of
+// the problem, followed by the underlying cause if any.
+func (p *Problem) Error() string {
+	msg := p.Title
+	if msg == "" {
+		msg = statusText(p.HTTPStatus())
+	}
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	if p.Err != nil {
+		msg += ": " + p.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause of the problem.
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// Is reports whether target is the HTTPError matching this problem's status,
+// so that errors.Is(p, StatusNotFound) works as expected.
+func (p *Problem) Is(target error) bool {
+	code, ok := target.(HTTPError)
+	return ok && int(code) == p.HTTPStatus()
+}
+
+// HTTPStatus returns the HTTP status code of the problem.
+func (p *Problem) HTTPStatus() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// ServeHTTP implements the http.Handler interface, rendering the problem with
+// the request's ErrorRenderer. Server errors with a cause are logged like
+// errors hidden by HideErrorDetails.
+func (p *Problem) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if p.HTTPStatus() >= 500 && p.Err != nil {
+		logServerError(w, req, p)
+	}
+	renderError(w, req, p.HTTPStatus(), p)
+}
+
+// MarshalJSON encodes the problem as a JSON object. Standard members come
+// first, followed by the extension members in key order.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	typ := p.Type
+	if typ == "" {
+		typ = "about:blank"
+	}
+	std, err := json.Marshal(&struct {
+		Type     string `json:"type"`
+		Title    string `json:"title,omitempty"`
+		Status   int    `json:"status"`
+		Detail   string `json:"detail,omitempty"`
+		Instance string `json:"instance,omitempty"`
+	}{typ, p.Title, p.HTTPStatus(), p.Detail, p.Instance})
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Extensions) == 0 {
+		return std, nil
+	}
+
+	keys := make([]string, 0, len(p.Extensions))
+	for k := range p.Extensions {
+		switch k {
+		case "type", "title", "status", "detail", "instance":
+			// Standard members cannot be overridden
+		default:
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer(std[:len(std)-1])
+	for _, k := range keys {
+		key, _ := json.Marshal(k)
+		val, err := json.Marshal(p.Extensions[k])
+		if err != nil {
+			return nil, fmt.Errorf("encoding problem extension %q: %w", k, err)
+		}
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a problem details object. Standard members with an
+// unexpected type are ignored as required by RFC 9457, and any other member
+// is stored in Extensions.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	*p = Problem{}
+	for k, raw := range obj {
+		// Standard members with an invalid type are ignored
+		switch k {
+		case "type":
+			_ = json.Unmarshal(raw, &p.Type)
+		case "title":
+			_ = json.Unmarshal(raw, &p.Title)
+		case "status":
+			_ = json.Unmarshal(raw, &p.Status)
+		case "detail":
+			_ = json.Unmarshal(raw, &p.Detail)
+		case "instance":
+			_ = json.Unmarshal(raw, &p.Instance)
+		default:
+			var v any
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]any)
+			}
+			p.Extensions[k] = v
+		}
+	}
+
+	return nil
+}
+
+// ParseProblem decodes a JSON problem details document.
+func ParseProblem(data []byte) (*Problem, error) {
+	p := &Problem{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("decoding problem details: %w", err)
+	}
+	return p, nil
+}
+
+// ReadProblem decodes the body of an HTTP response as a problem details
+// document. It returns ErrNotProblem if the response's Content-Type is not
+// application/problem+json. If the document has no status member, the
+// response's status code is used.
+//
+// The response body is read but not closed.
+func ReadProblem(resp *http.Response) (*Problem, error) {
+	mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.EqualFold(mt, mimeProblemJSON) {
+		return nil, ErrNotProblem
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProblemSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading problem details: %w", err)
+	}
+
+	p, err := ParseProblem(data)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == 0 {
+		p.Status = resp.StatusCode
+	}
+	return p, nil
+}
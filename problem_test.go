@@ -0,0 +1,67 @@
+package webutil_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+func TestProblem(t *testing.T) {
+	cause := errors.New("row not found")
+	p := webutil.NewProblem(http.StatusNotFound, "invoice 42 does not exist")
+	p.Type = "https://example.com/probs/no-invoice"
+	p.Instance = "/invoices/42"
+	p.Extensions = map[string]any{"invoice": "42", "status": "ignored"}
+	p.Err = cause
+
+	err := fmt.Errorf("loading invoice: %w", p)
+
+	if code := webutil.HTTPStatus(err); code != http.StatusNotFound {
+		t.Errorf("HTTPStatus: got %d, want %d", code, http.StatusNotFound)
+	}
+	if !errors.Is(err, webutil.StatusNotFound) {
+		t.Errorf("errors.Is(err, StatusNotFound) = false, want true")
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("errors.Is(err, cause) = false, want true")
+	}
+
+	// Serve the problem and parse it back as a client would
+	rec := httptest.NewRecorder()
+	webutil.ServeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), err)
+
+	resp := rec.Result()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Status mismatch: got %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	parsed, perr := webutil.ReadProblem(resp)
+	if perr != nil {
+		t.Fatalf("ReadProblem failed: %v", perr)
+	}
+	if parsed.Type != p.Type || parsed.Title != "Not Found" || parsed.Status != 404 ||
+		parsed.Detail != p.Detail || parsed.Instance != p.Instance {
+		t.Errorf("Round-trip mismatch: got %+v", parsed)
+	}
+	if parsed.Extensions["invoice"] != "42" {
+		t.Errorf("Extension mismatch: got %v, want %q", parsed.Extensions["invoice"], "42")
+	}
+	if parsed.Err != nil {
+		t.Errorf("Cause must not be serialized, got %v", parsed.Err)
+	}
+
+	// Non-problem responses are rejected
+	plain := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       io.NopCloser(nil),
+	}
+	if _, perr := webutil.ReadProblem(plain); !errors.Is(perr, webutil.ErrNotProblem) {
+		t.Errorf("ReadProblem on text/plain: got %v, want ErrNotProblem", perr)
+	}
+}
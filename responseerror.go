@@ -0,0 +1,142 @@
+package webutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBodySize is the maximum number of bytes of an error response body
+// kept by ResponseError.
+const maxErrorBodySize = 4 << 10 // 4KB
+
+// HTTPResponseError describes a non-successful HTTP response received by a
+// client. It is returned by ResponseError.
+//
+// It provides an HTTPStatus method and unwraps to the HTTPError matching the
+// response's status code, so that HTTPStatus(err) and
+// errors.Is(err, StatusNotFound) work as with server-side errors.
+type HTTPResponseError struct {
+	StatusCode int           // HTTP status code of the response
+	Status     string        // Status line of the response, e.g. "404 Not Found"
+	Method     string        // Method of the request
+	URL        string        // URL of the request, after redirects
+	Body       []byte        // Beginning of the response body, at most 4KB
+	Message    string        // Error message found in a JSON body, if any
+	Problem    *Problem      // Problem details, if the body was application/problem+json
+	RetryAfter time.Duration // Delay requested by the Retry-After header, if any
+}
+
+// ResponseError builds an error from a non-successful HTTP response. It keeps
+// the beginning of the body, extracts the error message from JSON and
+// problem+json bodies, and parses the Retry-After header.
+//
+// The response body is consumed and closed.
+func ResponseError(resp *http.Response) error {
+	e := &HTTPResponseError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		if resp.Request.URL != nil {
+			e.URL = resp.Request.URL.String()
+		}
+	}
+
+	if resp.Body != nil {
+		e.Body, _ = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		discardAndCloseBody(resp)
+	}
+
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mt == mimeProblemJSON:
+		if p, err := ParseProblem(e.Body); err == nil {
+			if p.Status == 0 {
+				p.Status = resp.StatusCode
+			}
+			e.Problem = p
+			e.Message = p.Detail
+			if e.Message == "" {
+				e.Message = p.Title
+			}
+		}
+	case mt == mimeJSON || strings.HasSuffix(mt, "+json"):
+		e.Message = jsonErrorMessage(e.Body)
+	}
+
+	return e
+}
+
+// jsonErrorMessage looks for an error message in the common members of JSON
+// error bodies, returning an empty string if none is found.
+func jsonErrorMessage(data []byte) string {
+	var obj map[string]any
+	if json.Unmarshal(data, &obj) != nil {
+		return ""
+	}
+
+	for _, k := range []string{"error_description", "message", "error", "detail", "title"} {
+		switch v := obj[k].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case map[string]any:
+			// {"error": {"message": "..."}}
+			if msg, ok := v["message"].(string); ok && msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
+
+// parseRetryAfter parses the value of a Retry-After header, given either as
+// a number of seconds or as an HTTP date. It returns 0 if the value is empty,
+// invalid or in the past.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// Error returns a description of the failed request and its status.
+func (e *HTTPResponseError) Error() string {
+	msg := fmt.Sprintf("HTTP error %d: %s", e.StatusCode, statusText(e.StatusCode))
+	if e.URL != "" {
+		msg = e.Method + " " + e.URL + ": " + msg
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// HTTPStatus returns the status code of the response.
+func (e *HTTPResponseError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// Unwrap returns the HTTPError matching the response's status code.
+func (e *HTTPResponseError) Unwrap() error {
+	return HTTPError(e.StatusCode)
+}
@@ -0,0 +1,74 @@
+package webutil_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+func TestResponseError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/problem", func(w http.ResponseWriter, req *http.Request) {
+		webutil.NewProblem(http.StatusNotFound, "invoice 42 does not exist").ServeHTTP(w, req)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"message":"rate limited","code":42}}`))
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "backend exploded", http.StatusBadGateway)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	testCases := []struct {
+		path       string
+		code       int
+		target     error
+		message    string
+		retryAfter time.Duration
+	}{
+		{"/problem", http.StatusNotFound, webutil.StatusNotFound, "invoice 42 does not exist", 0},
+		{"/json", http.StatusTooManyRequests, webutil.StatusTooManyRequests, "rate limited", 2 * time.Minute},
+		{"/text", http.StatusBadGateway, webutil.StatusBadGateway, "", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			_, err := webutil.Get(srv.URL + tc.path)
+			if err == nil {
+				t.Fatalf("Get succeeded, want error")
+			}
+
+			if code := webutil.HTTPStatus(err); code != tc.code {
+				t.Errorf("HTTPStatus: got %d, want %d", code, tc.code)
+			}
+			if !errors.Is(err, tc.target) {
+				t.Errorf("errors.Is(err, %v) = false, want true", tc.target)
+			}
+
+			var respErr *webutil.HTTPResponseError
+			if !errors.As(err, &respErr) {
+				t.Fatalf("errors.As(err, *HTTPResponseError) = false, want true")
+			}
+			if respErr.Message != tc.message {
+				t.Errorf("Message mismatch: got %q, want %q", respErr.Message, tc.message)
+			}
+			if respErr.RetryAfter != tc.retryAfter {
+				t.Errorf("RetryAfter mismatch: got %v, want %v", respErr.RetryAfter, tc.retryAfter)
+			}
+			if respErr.Method != http.MethodGet || respErr.URL != srv.URL+tc.path {
+				t.Errorf("Request mismatch: got %s %s", respErr.Method, respErr.URL)
+			}
+			if len(respErr.Body) == 0 {
+				t.Errorf("Body snippet is empty")
+			}
+		})
+	}
+}
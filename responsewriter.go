@@ -0,0 +1,125 @@
+package webutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+)
+
+// ErrorTrailer, if not empty, is the name of the HTTP trailer used to report
+// errors returned by a Handler after it started writing the response. By
+// default such errors abort the response, since a second status cannot be
+// sent; with ErrorTrailer set, the response is completed normally and the
+// error is sent in the trailer instead. Clients must check the trailer to
+// detect the failure.
+//
+// When set, the trailer is declared in every response started by a Wrapper
+// or WrapFunc handler, which requires chunked encoding on HTTP/1.1.
+//
+// It should be set during program initialization, before any request is served.
+var ErrorTrailer string
+
+// trackingWriter wraps an http.ResponseWriter to record whether the response
+// has been started, that is whether headers have been sent.
+type trackingWriter struct {
+	http.ResponseWriter
+	started  bool
+	hijacked bool
+}
+
+// start records that the response has started. When ErrorTrailer is set,
+// it is declared so that it can be sent if an error happens later on.
+func (t *trackingWriter) start() {
+	if t.started {
+		return
+	}
+	t.started = true
+	if ErrorTrailer != "" {
+		t.Header().Add("Trailer", ErrorTrailer)
+	}
+}
+
+// WriteHeader records that the response has started and forwards the call.
+func (t *trackingWriter) WriteHeader(code int) {
+	// 1xx informational responses do not start the final response
+	if code >= 200 {
+		t.start()
+	}
+	t.ResponseWriter.WriteHeader(code)
+}
+
+// Write records that the response has started and forwards the call.
+func (t *trackingWriter) Write(b []byte) (int, error) {
+	t.start()
+	return t.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying writer supports it.
+func (t *trackingWriter) Flush() {
+	t.start()
+	_ = http.NewResponseController(t.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker, returning http.ErrNotSupported if the
+// underlying writer does not support it.
+func (t *trackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(t.ResponseWriter).Hijack()
+	if err == nil {
+		t.started, t.hijacked = true, true
+	}
+	return conn, rw, err
+}
+
+// ReadFrom implements io.ReaderFrom, allowing the underlying writer to use
+// optimizations such as sendfile.
+func (t *trackingWriter) ReadFrom(r io.Reader) (int64, error) {
+	t.start()
+	if rf, ok := t.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(struct{ io.Writer }{t.ResponseWriter}, r)
+}
+
+// Unwrap returns the underlying writer, for use by http.ResponseController.
+func (t *trackingWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// serveStartedError handles an error returned by a handler after the response
+// was started. The error is reported to ErrorLogger, then either sent in the
+// ErrorTrailer or causes the response to be aborted.
+func serveStartedError(t *trackingWriter, req *http.Request, err error) {
+	code := memberStatus(err)
+	var id string
+	if perr, ok := err.(*PanicError); ok {
+		// Already reported to PanicLogger
+		id = perr.ID
+	} else {
+		id = newErrorID()
+		if ErrorLogger != nil {
+			ErrorLogger(req, err, id)
+		} else {
+			log.Printf("webutil: error %s after response started serving %s %s: %s", id, req.Method, req.URL.Path, err)
+		}
+	}
+
+	if t.hijacked {
+		// The connection is no longer managed by the server
+		return
+	}
+
+	if ErrorTrailer == "" {
+		// A second status cannot be sent, abort the response so the client
+		// can tell it is incomplete
+		panic(http.ErrAbortHandler)
+	}
+
+	msg := err.Error()
+	if HideErrorDetails {
+		msg = fmt.Sprintf("%s (error ID: %s)", statusText(code), id)
+	}
+	t.Header().Set(ErrorTrailer, fmt.Sprintf("%d %s", code, msg))
+}
@@ -3,10 +3,13 @@ package webutil
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,14 +18,26 @@ import (
 // resumeGET implements an io.ReadCloser that automatically resumes downloads
 // when connections are interrupted.
 type resumeGET struct {
-	req    *http.Request
-	resp   *http.Response
-	client *http.Client
-	pos    int64              // current position in bytes
-	size   int64              // total size in bytes (if known)
-	ctx    context.Context    // context for reading response
-	cancel context.CancelFunc // cancel method of the context
-	mu     sync.Mutex         // protects resp during timeout handling
+	req       *http.Request
+	resp      *http.Response
+	client    *http.Client
+	pos       int64              // current position in bytes
+	size      int64              // total size in bytes (if known)
+	ctx       context.Context    // context of the whole download
+	cancel    context.CancelFunc // cancel method of ctx
+	reqCancel context.CancelFunc // cancel method of the current request's context
+	mu        sync.Mutex         // protects resp and reqCancel during timeout handling
+	retry     RetryPolicy        // policy for resuming interrupted downloads
+	failures  []error            // failures since the download last made progress
+	err       error              // final error, returned by all subsequent reads
+	etag      string             // strong ETag of the resource, if any
+	modified  string             // Last-Modified date of the resource, if any
+	noRanges  bool               // server does not support range requests
+	maxSkip   int64              // maximum offset resumed by skipping data
+	limit     int64              // end offset of the segment being downloaded, 0 for the whole resource
+
+	connectTimeout time.Duration // time allowed to receive response headers
+	stallTimeout   time.Duration // time allowed for a single Read
 }
 
 // getResp safely returns the current response, protected by mutex.
@@ -64,20 +79,91 @@ func discardAndCloseBody(resp *http.Response) {
 	resp.Body.Close()
 }
 
+// Getter downloads resources with automatic resumption of interrupted
+// transfers, see Get. The zero value uses http.DefaultClient with the
+// default timeouts.
+type Getter struct {
+	// Client performs the requests, http.DefaultClient if nil
+	Client *http.Client
+
+	// Header holds headers added to every request, such as Authorization or
+	// User-Agent
+	Header http.Header
+
+	// ConnectTimeout limits the time to receive the response headers of each
+	// request, 30 seconds if zero. A negative value disables the limit.
+	ConnectTimeout time.Duration
+
+	// StallTimeout limits the time a single Read waits for data before the
+	// connection is considered stalled and the download resumed, 30 seconds
+	// if zero. A negative value disables the limit. Stalls are reported as
+	// errors matching os.ErrDeadlineExceeded.
+	StallTimeout time.Duration
+
+	// Timeout limits the duration of the whole download, including reading
+	// the body, no limit if zero.
+	Timeout time.Duration
+
+	// Retry controls how interrupted downloads are resumed
+	Retry RetryPolicy
+
+	// Segments is the number of concurrent connections used by GetSegmented
+	// and DownloadSegmented, 4 if zero
+	Segments int
+
+	// SegmentSize is the size of the segments requested by GetSegmented and
+	// DownloadSegmented, DefaultSegmentSize if zero
+	SegmentSize int64
+
+	// MaxSkip enables resuming downloads from servers that do not support
+	// range requests, by downloading the resource again and discarding the
+	// data already read, as long as it is at most MaxSkip bytes. Zero
+	// disables this fallback, and a negative value removes the limit.
+	MaxSkip int64
+}
+
+// defaultGetter is used by Get and GetContext.
+var defaultGetter = &Getter{}
+
 // Get retrieves content from a URL or data URI and returns it as an io.ReadCloser.
 //
 // For HTTP/HTTPS URLs, it returns an io.ReadCloser that will:
 // 1. Automatically resume the download if the connection is interrupted
-// 2. Handle proper error reporting based on HTTP status codes
+// 2. Return an *HTTPResponseError describing non-successful responses
 // 3. Use Range headers for transparent resuming when connections fail mid-download
+// 4. Send the resource's ETag or Last-Modified date as If-Range when resuming,
+// failing with a *ResourceChangedError if the resource changed
 //
 // For data URIs (URLs starting with "data:"), it decodes the embedded data
 // and returns it directly without any network request.
 //
 // Limitations for HTTP requests:
-// - If the server doesn't support Range headers, it can't resume
+// - If the server doesn't support Range headers, it can't resume unless
+// Getter.MaxSkip allows downloading the resource again
 // - If Content-Length isn't provided, size tracking won't be accurate
+//
+// Get uses http.DefaultClient and default timeouts; use a Getter to configure
+// them, or GetContext to control cancellation.
 func Get(url string) (io.ReadCloser, error) {
+	return defaultGetter.GetContext(context.Background(), url)
+}
+
+// GetContext is like Get, the download being aborted when ctx is cancelled,
+// including while reading the returned body.
+func GetContext(ctx context.Context, url string) (io.ReadCloser, error) {
+	return defaultGetter.GetContext(ctx, url)
+}
+
+// Get retrieves content from a URL or data URI like the package-level Get,
+// using the Getter's settings.
+func (g *Getter) Get(url string) (io.ReadCloser, error) {
+	return g.GetContext(context.Background(), url)
+}
+
+// GetContext retrieves content from a URL or data URI like the package-level
+// Get, using the Getter's settings. The download is aborted when ctx is
+// cancelled, including while reading the returned body.
+func (g *Getter) GetContext(ctx context.Context, url string) (io.ReadCloser, error) {
 	if strings.HasPrefix(url, "data:") {
 		// handle data uri
 		buf, _, err := ParseDataURI(url)
@@ -87,153 +173,515 @@ func Get(url string) (io.ReadCloser, error) {
 		return io.NopCloser(bytes.NewReader(buf)), nil
 	}
 
-	// Use context for better control and potential timeout in the future
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// we use a separate context so we're able to *not* cancel it once the request
-	// is done and we start reading
-	gctx, gcancel := context.WithCancel(context.Background())
-
-	stopCancel := context.AfterFunc(ctx, gcancel)
-	defer stopCancel()
-
-	req, err := http.NewRequestWithContext(gctx, http.MethodGet, url, nil)
+	// The download context lives until the body is closed
+	ctx, cancel := g.downloadContext(ctx)
+	getter, err := g.newResumeGET(ctx, cancel, url)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, err
 	}
 
-	// DefaultClient handles redirects for us
-	resp, err := http.DefaultClient.Do(req)
+	// The client handles redirects for us
+	resp, err := getter.do(getter.req)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("performing request: %w", err)
 	}
 
-	// call stop() now
-	stopCancel()
-
 	// Check if the status code indicates success
 	switch resp.StatusCode {
 	case http.StatusOK, http.StatusNoContent:
 		// Success, continue
 	default:
 		// Error status, clean up and return an error
-		gcancel()
-		discardAndCloseBody(resp)
-		return nil, HTTPError(resp.StatusCode)
+		defer getter.Close()
+		return nil, ResponseError(resp)
 	}
 
-	// Create a resumeGET object that can handle interrupted downloads
-	getter := &resumeGET{
-		// Use resp.Request to retain any redirects that occurred
-		req:    resp.Request,
-		resp:   resp,
-		size:   resp.ContentLength,
-		client: http.DefaultClient,
-		ctx:    gctx,
-		cancel: gcancel,
+	getter.start(resp)
+	return getter, nil
+}
+
+// downloadContext returns the context of a download, which lives until the
+// download is closed or its timeout expires.
+func (g *Getter) downloadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.Timeout > 0 {
+		return context.WithTimeout(ctx, g.Timeout)
 	}
+	return context.WithCancel(ctx)
+}
 
-	return getter, nil
+// newResumeGET returns a resumeGET for url using the Getter's settings, ctx
+// and cancel being the context of the download and its cancel method. The
+// context is cancelled if an error is returned.
+func (g *Getter) newResumeGET(ctx context.Context, cancel context.CancelFunc, url string) (*resumeGET, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	for k, v := range g.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		// Without this, the transport asks for gzip and decompresses it
+		// transparently: offsets and validators would then refer to the
+		// compressed representation while ranges are requested in the
+		// original one
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+
+	r := &resumeGET{
+		req:            req,
+		client:         g.Client,
+		ctx:            ctx,
+		cancel:         cancel,
+		connectTimeout: defaultTimeout(g.ConnectTimeout),
+		stallTimeout:   defaultTimeout(g.StallTimeout),
+		retry:          g.Retry,
+		maxSkip:        g.MaxSkip,
+	}
+	if r.client == nil {
+		r.client = http.DefaultClient
+	}
+	return r, nil
+}
+
+// start initializes the download from the successful response to its first
+// request.
+func (r *resumeGET) start(resp *http.Response) {
+	// Use resp.Request to retain any redirects that occurred
+	r.req = resp.Request
+	r.resp = resp
+	r.size = resp.ContentLength
+	r.etag = resp.Header.Get("ETag")
+	if strings.HasPrefix(r.etag, "W/") {
+		// Weak validators cannot be used with If-Range
+		r.etag = ""
+	}
+	r.modified = resp.Header.Get("Last-Modified")
+	if ar := resp.Header.Get("Accept-Ranges"); ar != "" && !strings.Contains(strings.ToLower(ar), "bytes") {
+		// The server announced it does not support byte ranges, typically
+		// with "Accept-Ranges: none"
+		r.noRanges = true
+	}
+}
+
+// defaultTimeout returns the effective value of a timeout setting, 0 meaning
+// no timeout.
+func defaultTimeout(d time.Duration) time.Duration {
+	switch {
+	case d == 0:
+		return 30 * time.Second
+	case d < 0:
+		return 0
+	default:
+		return d
+	}
+}
+
+// do performs req with a new context derived from the download's context,
+// which is cancelled if the response headers are not received within the
+// connect timeout. The context's cancel method is stored in reqCancel.
+func (r *resumeGET) do(req *http.Request) (*http.Response, error) {
+	rctx, rcancel := context.WithCancel(r.ctx)
+
+	var timer *time.Timer
+	if r.connectTimeout > 0 {
+		timer = time.AfterFunc(r.connectTimeout, rcancel)
+	}
+
+	resp, err := r.client.Do(req.Clone(rctx))
+	if timer != nil && !timer.Stop() {
+		// The timeout fired, the response cannot be used
+		if err == nil {
+			resp.Body.Close()
+		}
+		rcancel()
+		return nil, fmt.Errorf("no response within %s: %w", r.connectTimeout, context.DeadlineExceeded)
+	}
+	if err != nil {
+		rcancel()
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.reqCancel = rcancel
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// cancelRequest cancels the context of the current request, if any.
+func (r *resumeGET) cancelRequest() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reqCancel != nil {
+		r.reqCancel()
+		r.reqCancel = nil
+	}
 }
 
 // Read implements io.Reader, handling automatic resumption of interrupted downloads.
 func (r *resumeGET) Read(b []byte) (int, error) {
-	// If we have an active response, try to read from it
-	if resp := r.getResp(); resp != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		// Defer AfterFunc's stop so that it's cancelled before we cancel the context
-		stop := context.AfterFunc(ctx, func() {
-			// timeout, let's just close the connection, this will trigger re-opening it on the next call
-			// (this is useful to detect connection stalling, but may cause slow connections to go into an
-			// infinite loop, this said the typical buffer size we get is 32k, which would mean you'd need
-			// to download at 8kbps per second for this to be an issue. Even dial up modems have more
-			// bandwidth than that).
-			r.cancel()
-			if resp := r.takeResp(); resp != nil {
-				resp.Body.Close()
+	for {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.limit > 0 {
+			// Don't read past the end of the segment
+			if r.pos >= r.limit {
+				return 0, io.EOF
+			}
+			if int64(len(b)) > r.limit-r.pos {
+				b = b[:r.limit-r.pos]
 			}
-		})
-		defer stop()
+		}
 
-		n, err := resp.Body.Read(b)
+		resp := r.getResp()
+		if resp == nil {
+			// No active response or previous response had an error, attempt to resume
+			if err := r.resume(); err != nil {
+				r.err = err
+				return 0, err
+			}
+			continue
+		}
 
-		// If we read data, update position and return, even if there was an error
-		// This ensures we return the data we have before handling any error
+		n, err := r.readBody(resp, b)
+		end := r.end()
+		done := (end >= 0 && r.pos >= end) || (end < 0 && err == io.EOF)
+
+		// If we read data, update position and return it, resuming on the
+		// next call if the body failed before its end
 		if n > 0 {
-			r.pos += int64(n)
+			r.failures = nil
+			if err != nil && !done {
+				r.interrupted(err)
+				err = nil
+			} else if err != nil {
+				err = io.EOF
+			}
 			return n, err
 		}
 
+		if err == nil {
+			return 0, nil
+		}
 		// Error with no data read: decide if we should resume or return EOF
-		if err != nil {
-			// If we've already read the entire content or size is unknown and err is EOF,
-			// we're done
-			if (r.size > 0 && r.pos >= r.size) || (r.size < 0 && err == io.EOF) {
-				return 0, io.EOF
-			}
-
-			// Otherwise, close the current response to prepare for resumption
-			if resp := r.takeResp(); resp != nil {
-				resp.Body.Close()
-			}
+		if done {
+			return 0, io.EOF
 		}
+		r.interrupted(err)
+	}
+}
+
+// readBody reads from the body of resp and updates the position.
+func (r *resumeGET) readBody(resp *http.Response, b []byte) (int, error) {
+	n, err := r.readStall(resp, b)
+	r.pos += int64(n)
+	return n, err
+}
 
-		stop()
+// readStall reads from the body of resp, cancelling the request if no data
+// is received within the stall timeout.
+func (r *resumeGET) readStall(resp *http.Response, b []byte) (int, error) {
+	if r.stallTimeout <= 0 {
+		return resp.Body.Read(b)
 	}
 
-	// No active response or previous response had an error, attempt to resume
-	return r.resumeDownload(b)
+	timer := time.AfterFunc(r.stallTimeout, func() {
+		// timeout, let's just close the connection, this will trigger re-opening it on the next call
+		// (this is useful to detect connection stalling, but may cause slow connections to go into an
+		// infinite loop, this said the typical buffer size we get is 32k, which would mean you'd need
+		// to download at 8kbps per second for this to be an issue. Even dial up modems have more
+		// bandwidth than that).
+		r.cancelRequest()
+		if resp := r.takeResp(); resp != nil {
+			resp.Body.Close()
+		}
+	})
+	n, err := resp.Body.Read(b)
+	if !timer.Stop() && err != io.EOF {
+		// Report the stall rather than the cancellation it caused
+		err = fmt.Errorf("no data received within %s: %w", r.stallTimeout, os.ErrDeadlineExceeded)
+	}
+	return n, err
 }
 
-// resumeDownload attempts to resume an interrupted download
-// using Range headers.
-func (r *resumeGET) resumeDownload(b []byte) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// interrupted records the error that interrupted the current response, and
+// closes it to prepare for resumption.
+func (r *resumeGET) interrupted(err error) {
+	if resp := r.takeResp(); resp != nil {
+		resp.Body.Close()
+	}
+	r.cancelRequest()
+	r.failures = append(r.failures, err)
+}
 
-	gctx, gcancel := context.WithCancel(context.Background())
+// resume attempts to resume an interrupted download using Range headers,
+// following the retry policy. It returns a *RetryError if the download could
+// not be resumed.
+func (r *resumeGET) resume() error {
+	for {
+		// Cancellation of the download is final
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		// Each failure since the download last made progress counts as an
+		// attempt, including interruptions of resumed responses
+		attempt := len(r.failures)
+		if limit := r.retry.maxAttempts(); limit > 0 && attempt > limit {
+			return &RetryError{Attempts: r.failures}
+		}
 
-	stopCancel := context.AfterFunc(ctx, gcancel)
+		delay := r.retry.backoff(attempt)
+		if len(r.failures) > 0 {
+			after, ok := r.retry.retryAfter(r.failures[len(r.failures)-1])
+			if !ok {
+				return &RetryError{Attempts: r.failures}
+			}
+			if after > delay {
+				delay = after
+			}
+		}
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-r.ctx.Done():
+				timer.Stop()
+				return r.ctx.Err()
+			case <-timer.C:
+			}
+		}
 
-	// Create a new request with the context instead of modifying the original
-	req := r.req.WithContext(gctx)
+		err := r.resumeDownload()
+		if err == nil {
+			return nil
+		}
+		if ctxErr := r.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		r.failures = append(r.failures, err)
+	}
+}
 
-	log.Printf("Resuming download at %d", r.pos)
+// resumeDownload performs a single attempt to resume the download from the
+// current position.
+func (r *resumeGET) resumeDownload() error {
+	req := r.req.Clone(r.ctx)
+	if r.noRanges {
+		// Download again from the start and skip the data already read
+		if !r.canSkip() {
+			return fmt.Errorf("resuming download at offset %d: %w", r.pos, ErrRangeNotSupported)
+		}
+		log.Printf("Restarting download to resume at %d", r.pos)
+	} else {
+		if len(r.failures) > 0 {
+			log.Printf("Resuming download at %d", r.pos)
+		}
 
-	// Set Range header to resume from current position
-	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.pos))
+		// Set Range header to resume from current position
+		if r.limit > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.pos, r.limit-1))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.pos))
+		}
+		if ifRange := r.ifRange(); ifRange != "" {
+			// Only get the remainder if the resource did not change
+			req.Header.Set("If-Range", ifRange)
+		}
+	}
 
-	// Perform the request with the Range header
-	resp, err := r.client.Do(req)
+	resp, err := r.do(req)
 	if err != nil {
-		return 0, fmt.Errorf("resuming download: %w", err)
+		return fmt.Errorf("resuming download: %w", err)
 	}
 
-	stopCancel()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && !r.noRanges:
+		if err := r.checkPartial(resp); err != nil {
+			r.cancelRequest()
+			discardAndCloseBody(resp)
+			return err
+		}
+		// Store the new response
+		r.setResp(resp)
+		return nil
+	case resp.StatusCode == http.StatusOK:
+		// The whole resource was sent, either because the server ignored the
+		// Range header or because the If-Range condition failed
+		if err := r.checkFull(resp); err != nil {
+			r.cancelRequest()
+			discardAndCloseBody(resp)
+			return err
+		}
+		if !r.canSkip() {
+			r.cancelRequest()
+			discardAndCloseBody(resp)
+			return fmt.Errorf("resuming download at offset %d: %w", r.pos, ErrRangeNotSupported)
+		}
 
-	// Server must respond with 206 Partial Content for a successful range request
-	if resp.StatusCode != http.StatusPartialContent {
-		gcancel()
+		// Don't bother with ranges anymore
+		r.noRanges = true
+		if err := r.discard(resp, r.pos); err != nil {
+			r.cancelRequest()
+			resp.Body.Close()
+			return fmt.Errorf("skipping to offset %d: %w", r.pos, err)
+		}
+		r.setResp(resp)
+		return nil
+	case resp.StatusCode >= 300:
+		defer r.cancelRequest()
+		return ResponseError(resp)
+	default:
+		r.cancelRequest()
 		discardAndCloseBody(resp)
-		return 0, fmt.Errorf("expected 206 Partial Content, got %w", HTTPError(resp.StatusCode))
+		return fmt.Errorf("unexpected response when resuming download: %w", HTTPError(resp.StatusCode))
 	}
+}
 
-	// Store the new response
-	r.setResp(resp)
-	r.ctx = gctx
-	r.cancel = gcancel
+// end returns the offset at which the download ends, or -1 if unknown.
+func (r *resumeGET) end() int64 {
+	if r.limit > 0 {
+		return r.limit
+	}
+	return r.size
+}
 
-	// Read data from the new response
-	n, err := resp.Body.Read(b)
-	r.pos += int64(n)
-	return n, err
+// canSkip reports whether the download may be resumed by downloading the
+// resource again and skipping the data already read.
+func (r *resumeGET) canSkip() bool {
+	return r.maxSkip < 0 || (r.maxSkip > 0 && r.pos <= r.maxSkip)
+}
+
+// discard reads and discards the first n bytes of the body of resp.
+func (r *resumeGET) discard(resp *http.Response, n int64) error {
+	buf := make([]byte, 32<<10)
+	for n > 0 {
+		if int64(len(buf)) > n {
+			buf = buf[:n]
+		}
+		m, err := r.readStall(resp, buf)
+		n -= int64(m)
+		if err != nil && n > 0 {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFull verifies that a 200 response holds the resource being downloaded.
+func (r *resumeGET) checkFull(resp *http.Response) error {
+	if !r.sameResource(resp.Header) {
+		return r.changed("validators no longer match")
+	}
+	if r.size >= 0 && resp.ContentLength >= 0 && resp.ContentLength != r.size {
+		return r.changed(fmt.Sprintf("size changed from %d to %d bytes", r.size, resp.ContentLength))
+	}
+	return nil
+}
+
+// ifRange returns the value of the If-Range header sent when resuming, the
+// strong ETag of the resource or its Last-Modified date.
+func (r *resumeGET) ifRange() string {
+	if r.etag != "" {
+		return r.etag
+	}
+	return r.modified
+}
+
+// sameResource reports whether the validators in h, if any, match those of
+// the resource being downloaded.
+func (r *resumeGET) sameResource(h http.Header) bool {
+	if etag := h.Get("ETag"); r.etag != "" && etag != "" && etag != r.etag {
+		return false
+	}
+	if modified := h.Get("Last-Modified"); r.modified != "" && modified != "" && modified != r.modified {
+		return false
+	}
+	return true
+}
+
+// checkPartial verifies that a 206 response holds the remainder of the
+// resource being downloaded.
+func (r *resumeGET) checkPartial(resp *http.Response) error {
+	if !r.sameResource(resp.Header) {
+		return r.changed("validators no longer match")
+	}
+
+	cr := resp.Header.Get("Content-Range")
+	start, _, total, ok := parseContentRange(cr)
+	switch {
+	case !ok:
+		return fmt.Errorf("invalid Content-Range %q", cr)
+	case start != r.pos:
+		return r.changed(fmt.Sprintf("Content-Range %q does not start at offset %d", cr, r.pos))
+	case r.size >= 0 && total >= 0 && total != r.size:
+		return r.changed(fmt.Sprintf("size changed from %d to %d bytes", r.size, total))
+	}
+	return nil
+}
+
+// changed returns a *ResourceChangedError for the download.
+func (r *resumeGET) changed(reason string) error {
+	return &ResourceChangedError{URL: r.req.URL.String(), Offset: r.pos, Reason: reason}
+}
+
+// parseContentRange parses the value of a Content-Range header such as
+// "bytes 100-199/1000", total being -1 if the size is unknown.
+func parseContentRange(v string) (start, end, total int64, ok bool) {
+	v, found := strings.CutPrefix(v, "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	rng, size, found := strings.Cut(v, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	first, last, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	var err1, err2, err3 error
+	start, err1 = strconv.ParseInt(first, 10, 64)
+	end, err2 = strconv.ParseInt(last, 10, 64)
+	total = -1
+	if size != "*" {
+		total, err3 = strconv.ParseInt(size, 10, 64)
+	}
+	if err1 != nil || err2 != nil || err3 != nil || start < 0 || end < start || (total >= 0 && end >= total) {
+		return 0, 0, 0, false
+	}
+	return start, end, total, true
+}
+
+// ErrRangeNotSupported is returned when an interrupted download cannot be
+// resumed because the server does not support range requests, and
+// downloading the resource again is not allowed by Getter.MaxSkip.
+var ErrRangeNotSupported = errors.New("server does not support range requests")
+
+// ResourceChangedError is returned when an interrupted download cannot be
+// resumed because the resource changed on the server, as detected using its
+// ETag and Last-Modified validators and the Content-Range of the response.
+// The data read so far must then be discarded.
+type ResourceChangedError struct {
+	URL    string // URL of the resource
+	Offset int64  // Offset at which the download was to be resumed
+	Reason string // Description of the change
+}
+
+// Error returns a description of the change.
+func (e *ResourceChangedError) Error() string {
+	return fmt.Sprintf("resource %s changed during download at offset %d: %s", e.URL, e.Offset, e.Reason)
 }
 
-// Close implements io.Closer, ensuring the response body is properly closed.
+// Close implements io.Closer, ensuring the response body is properly closed
+// and the download's resources released.
 func (r *resumeGET) Close() error {
+	defer r.cancel()
 	if resp := r.takeResp(); resp != nil && resp.Body != nil {
 		return resp.Body.Close()
 	}
@@ -0,0 +1,462 @@
+package webutil_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+const resumeContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// resumeServer serves resumeContent, the first request stalling after half of
+// the body until the client gives up.
+func resumeServer(t *testing.T) (*httptest.Server, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if req.Header.Get("User-Agent") != "webutil-test" {
+			http.Error(w, "missing user agent", http.StatusBadRequest)
+			return
+		}
+
+		start := 0
+		if r := req.Header.Get("Range"); r != "" {
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r, "bytes="), "-"))
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(resumeContent)-1, len(resumeContent)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(resumeContent)-start))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(resumeContent)))
+		}
+
+		if n == 1 {
+			_, _ = io.WriteString(w, resumeContent[:len(resumeContent)/2])
+			w.(http.Flusher).Flush()
+			<-req.Context().Done()
+			return
+		}
+		_, _ = io.WriteString(w, resumeContent[start:])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestGetterResume(t *testing.T) {
+	srv, requests := resumeServer(t)
+
+	g := &webutil.Getter{
+		Header:       http.Header{"User-Agent": {"webutil-test"}},
+		StallTimeout: 50 * time.Millisecond,
+	}
+	body, err := g.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != resumeContent {
+		t.Errorf("Content mismatch: got %q", data)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+}
+
+func TestGetterCancel(t *testing.T) {
+	srv, _ := resumeServer(t)
+	g := &webutil.Getter{Header: http.Header{"User-Agent": {"webutil-test"}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body, err := g.GetContext(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("GetContext failed: %v", err)
+	}
+	defer body.Close()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := io.ReadAll(body); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadAll error: got %v, want context.Canceled", err)
+	}
+
+	// The overall deadline also applies to reads
+	srv, _ = resumeServer(t)
+	g.Timeout = 50 * time.Millisecond
+	body, err = g.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer body.Close()
+	if _, err := io.ReadAll(body); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ReadAll error: got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestGetterConnectTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	g := &webutil.Getter{ConnectTimeout: 50 * time.Millisecond}
+	_, err := g.Get(srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get error: got %v, want context.DeadlineExceeded", err)
+	}
+	if code := webutil.HTTPStatus(err); code != http.StatusGatewayTimeout {
+		t.Errorf("HTTPStatus: got %d, want %d", code, http.StatusGatewayTimeout)
+	}
+}
+
+func TestGetterRetry(t *testing.T) {
+	testCases := []struct {
+		name     string
+		statuses []int // statuses of the resume requests, then 206
+		header   http.Header
+		policy   webutil.RetryPolicy
+		requests int32
+		attempts int // failures in the RetryError, 0 for success
+		status   int // status of the last failure
+	}{
+		{"Success", []int{http.StatusServiceUnavailable, http.StatusBadGateway}, nil, webutil.RetryPolicy{}, 4, 0, 0},
+		{"RetryAfter", []int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"0"}}, webutil.RetryPolicy{}, 3, 0, 0},
+		{"Fatal", []int{http.StatusNotFound}, nil, webutil.RetryPolicy{}, 2, 2, http.StatusNotFound},
+		{"Range", []int{http.StatusRequestedRangeNotSatisfiable}, nil, webutil.RetryPolicy{}, 2, 2, http.StatusRequestedRangeNotSatisfiable},
+		{"MaxAttempts", []int{500, 500, 500, 500}, nil, webutil.RetryPolicy{MaxAttempts: 3}, 4, 4, http.StatusInternalServerError},
+		{"RetryAfterTooLong", []int{http.StatusServiceUnavailable}, http.Header{"Retry-After": {"120"}}, webutil.RetryPolicy{MaxRetryAfter: time.Second}, 2, 2, http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				n := int(atomic.AddInt32(&requests, 1))
+				if n == 1 {
+					// Abort the connection after half of the body
+					w.Header().Set("Content-Length", strconv.Itoa(len(resumeContent)))
+					_, _ = io.WriteString(w, resumeContent[:len(resumeContent)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				if n-2 < len(tc.statuses) {
+					for k, v := range tc.header {
+						w.Header()[k] = v
+					}
+					http.Error(w, "unavailable", tc.statuses[n-2])
+					return
+				}
+				start := len(resumeContent) / 2
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(resumeContent)-1, len(resumeContent)))
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = io.WriteString(w, resumeContent[start:])
+			}))
+			defer srv.Close()
+
+			policy := tc.policy
+			policy.InitialBackoff = time.Millisecond
+			body, err := (&webutil.Getter{Retry: policy}).Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			defer body.Close()
+
+			data, err := io.ReadAll(body)
+			if n := atomic.LoadInt32(&requests); n != tc.requests {
+				t.Errorf("Expected %d requests, got %d", tc.requests, n)
+			}
+			if tc.attempts == 0 {
+				if err != nil {
+					t.Fatalf("ReadAll failed: %v", err)
+				}
+				if string(data) != resumeContent {
+					t.Errorf("Content mismatch: got %q", data)
+				}
+				return
+			}
+
+			var retryErr *webutil.RetryError
+			if !errors.As(err, &retryErr) {
+				t.Fatalf("ReadAll error: got %v, want *RetryError", err)
+			}
+			if len(retryErr.Attempts) != tc.attempts {
+				t.Errorf("Expected %d attempts, got %d: %v", tc.attempts, len(retryErr.Attempts), err)
+			}
+			if msg := fmt.Sprintf("interrupted, then %d failed resume attempt", tc.attempts-1); !strings.Contains(err.Error(), msg) {
+				t.Errorf("Error message %q does not contain %q", err, msg)
+			}
+			if code := webutil.HTTPStatus(retryErr.Attempts[len(retryErr.Attempts)-1]); code != tc.status {
+				t.Errorf("Last attempt status: got %d, want %d", code, tc.status)
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("Expected the interruption in %v", err)
+			}
+			if _, err2 := body.Read(make([]byte, 1)); err2 != err {
+				t.Errorf("Subsequent Read: got %v, want %v", err2, err)
+			}
+		})
+	}
+}
+
+func TestGetterStall(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		start := 0
+		if n > 1 {
+			start = len(resumeContent) / 2
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(resumeContent)-1, len(resumeContent)))
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(resumeContent)-start))
+		if n > 1 {
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		if n == 1 {
+			_, _ = io.WriteString(w, resumeContent[:len(resumeContent)/2])
+		}
+		// Stall until the client gives up
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+
+	g := &webutil.Getter{
+		StallTimeout: 20 * time.Millisecond,
+		Retry:        webutil.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+	}
+	body, err := g.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer body.Close()
+
+	_, err = io.ReadAll(body)
+	var retryErr *webutil.RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("ReadAll error: got %v, want *RetryError", err)
+	}
+	if len(retryErr.Attempts) != 3 {
+		t.Errorf("Expected 3 attempts, got %d: %v", len(retryErr.Attempts), err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected os.ErrDeadlineExceeded in %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected context.Canceled in %v", err)
+	}
+	if code := webutil.HTTPStatus(err); code != http.StatusGatewayTimeout {
+		t.Errorf("HTTPStatus: got %d, want %d", code, http.StatusGatewayTimeout)
+	}
+}
+
+func TestGetterIfRange(t *testing.T) {
+	const modified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	half := len(resumeContent) / 2
+	partial := func(w http.ResponseWriter, start, total int) {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(resumeContent)-1, total))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, resumeContent[start:])
+	}
+
+	testCases := []struct {
+		name    string
+		etag    string // ETag of the first response
+		ifRange string // expected If-Range header
+		resume  func(w http.ResponseWriter)
+		changed bool
+	}{
+		{"ETag", `"v1"`, `"v1"`, func(w http.ResponseWriter) { partial(w, half, len(resumeContent)) }, false},
+		{"WeakETag", `W/"v1"`, modified, func(w http.ResponseWriter) { partial(w, half, len(resumeContent)) }, false},
+		{"Changed", `"v1"`, `"v1"`, func(w http.ResponseWriter) {
+			w.Header().Set("ETag", `"v2"`)
+			_, _ = io.WriteString(w, strings.ToUpper(resumeContent))
+		}, true},
+		{"ChangedPartial", `"v1"`, `"v1"`, func(w http.ResponseWriter) {
+			w.Header().Set("ETag", `"v2"`)
+			partial(w, half, len(resumeContent))
+		}, true},
+		{"Offset", `"v1"`, `"v1"`, func(w http.ResponseWriter) { partial(w, half+1, len(resumeContent)) }, true},
+		{"Size", `"v1"`, `"v1"`, func(w http.ResponseWriter) { partial(w, half, len(resumeContent)+1) }, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if atomic.AddInt32(&requests, 1) == 1 {
+					// Abort the connection after half of the body
+					w.Header().Set("ETag", tc.etag)
+					w.Header().Set("Last-Modified", modified)
+					w.Header().Set("Content-Length", strconv.Itoa(len(resumeContent)))
+					_, _ = io.WriteString(w, resumeContent[:half])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				if v := req.Header.Get("If-Range"); v != tc.ifRange {
+					t.Errorf("If-Range: got %q, want %q", v, tc.ifRange)
+				}
+				tc.resume(w)
+			}))
+			defer srv.Close()
+
+			body, err := webutil.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			defer body.Close()
+
+			data, err := io.ReadAll(body)
+			if n := atomic.LoadInt32(&requests); n != 2 {
+				t.Errorf("Expected 2 requests, got %d", n)
+			}
+			var changedErr *webutil.ResourceChangedError
+			if got := errors.As(err, &changedErr); got != tc.changed {
+				t.Fatalf("ReadAll error: got %v, want ResourceChangedError %v", err, tc.changed)
+			}
+			if !tc.changed && string(data) != resumeContent {
+				t.Errorf("Content mismatch: got %q", data)
+			}
+			if tc.changed && changedErr.Offset != int64(half) {
+				t.Errorf("Offset: got %d, want %d", changedErr.Offset, half)
+			}
+		})
+	}
+}
+
+func TestGetterEncoding(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Negotiate the encoding, then abort the connection after half
+			// of the body
+			body := []byte(resumeContent)
+			if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+				var buf bytes.Buffer
+				zw := gzip.NewWriter(&buf)
+				_, _ = zw.Write(body)
+				zw.Close()
+				body = buf.Bytes()
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Header().Set("ETag", `"v1-gzip"`)
+			} else {
+				w.Header().Set("ETag", `"v1"`)
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			_, _ = w.Write(body[:len(body)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		if req.Header.Get("If-Range") != `"v1"` {
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = io.WriteString(w, resumeContent)
+			return
+		}
+		half := len(resumeContent) / 2
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(resumeContent)-1, len(resumeContent)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, resumeContent[half:])
+	}))
+	defer srv.Close()
+
+	body, err := webutil.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != resumeContent {
+		t.Errorf("Content mismatch: got %q", data)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+}
+
+func TestGetterSkip(t *testing.T) {
+	testCases := []struct {
+		name         string
+		acceptRanges string
+		maxSkip      int64
+		requests     int32
+		ok           bool
+	}{
+		{"Skip", "", -1, 2, true},
+		{"SkipCap", "", int64(len(resumeContent) / 2), 2, true},
+		{"OverCap", "", 10, 2, false},
+		{"Disabled", "", 0, 2, false},
+		{"AcceptRangesNone", "none", -1, 2, true},
+		{"AcceptRangesNoneDisabled", "none", 0, 1, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				if tc.acceptRanges != "" {
+					w.Header().Set("Accept-Ranges", tc.acceptRanges)
+					if r := req.Header.Get("Range"); r != "" {
+						t.Errorf("Unexpected Range header %q", r)
+					}
+				}
+				// Range is always ignored
+				w.Header().Set("Content-Length", strconv.Itoa(len(resumeContent)))
+				if n == 1 {
+					_, _ = io.WriteString(w, resumeContent[:len(resumeContent)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				_, _ = io.WriteString(w, resumeContent)
+			}))
+			defer srv.Close()
+
+			body, err := (&webutil.Getter{MaxSkip: tc.maxSkip}).Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			defer body.Close()
+
+			data, err := io.ReadAll(body)
+			if n := atomic.LoadInt32(&requests); n != tc.requests {
+				t.Errorf("Expected %d requests, got %d", tc.requests, n)
+			}
+			if !tc.ok {
+				if !errors.Is(err, webutil.ErrRangeNotSupported) {
+					t.Errorf("ReadAll error: got %v, want ErrRangeNotSupported", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadAll failed: %v", err)
+			}
+			if string(data) != resumeContent {
+				t.Errorf("Content mismatch: got %q", data)
+			}
+		})
+	}
+}
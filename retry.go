@@ -0,0 +1,145 @@
+package webutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how downloads started by Get and Getter are resumed
+// after a failure. The zero value uses the defaults documented on each field.
+//
+// Resuming is attempted at most MaxAttempts times in a row, the first attempt
+// being immediate and the next ones waiting an exponentially growing delay.
+// The count is reset once the download makes progress again. Responses with a
+// status other than 408, 429, 500, 502, 503 or 504 end the download
+// immediately, as do changes of the resource (see ResourceChangedError) and
+// servers not supporting range requests (see ErrRangeNotSupported), and the
+// Retry-After header of 429 and 503 responses is honored.
+type RetryPolicy struct {
+	// MaxAttempts is the number of consecutive attempts to resume a download,
+	// 5 if zero. A negative value retries indefinitely.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the second attempt, 1 second if zero
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts, 30 seconds if zero
+	MaxBackoff time.Duration
+
+	// Multiplier is the growth factor of the delay, 2 if zero
+	Multiplier float64
+
+	// Jitter is the fraction of each delay that is randomized, 0.5 if zero.
+	// A negative value disables jitter.
+	Jitter float64
+
+	// MaxRetryAfter is the longest Retry-After delay honored, 2 minutes if
+	// zero. Responses asking to wait longer end the download.
+	MaxRetryAfter time.Duration
+}
+
+// RetryError is returned by downloads that could not be resumed. It holds the
+// error that interrupted the download followed by the failure of each attempt
+// to resume it, and matches any of them with errors.Is and errors.As.
+type RetryError struct {
+	Attempts []error
+}
+
+// Error lists the interruption and the failures of all attempts.
+func (e *RetryError) Error() string {
+	msgs := make([]string, len(e.Attempts))
+	for i, err := range e.Attempts {
+		msgs[i] = err.Error()
+	}
+	n := len(e.Attempts) - 1
+	if n < 0 {
+		n = 0
+	}
+	plural := "s"
+	if n == 1 {
+		plural = ""
+	}
+	return fmt.Sprintf("download interrupted, then %d failed resume attempt%s: %s", n, plural, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the failures of all attempts.
+func (e *RetryError) Unwrap() []error {
+	return e.Attempts
+}
+
+// maxAttempts returns the maximum number of consecutive attempts, 0 meaning
+// no limit.
+func (p *RetryPolicy) maxAttempts() int {
+	switch {
+	case p.MaxAttempts == 0:
+		return 5
+	case p.MaxAttempts < 0:
+		return 0
+	default:
+		return p.MaxAttempts
+	}
+}
+
+// backoff returns the delay to wait before the given attempt, starting at 1.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+
+	initial, maxDelay, mult, jitter := p.InitialBackoff, p.MaxBackoff, p.Multiplier, p.Jitter
+	if initial <= 0 {
+		initial = time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+	if mult <= 0 {
+		mult = 2
+	}
+	if jitter == 0 {
+		jitter = 0.5
+	}
+
+	d := float64(initial) * math.Pow(mult, float64(attempt-2))
+	if d > float64(maxDelay) {
+		d = float64(maxDelay)
+	}
+	if jitter > 0 {
+		d -= d * jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// retryAfter returns the delay requested by a failed attempt through the
+// Retry-After header, and whether the download may be retried at all.
+func (p *RetryPolicy) retryAfter(err error) (time.Duration, bool) {
+	var changedErr *ResourceChangedError
+	if errors.As(err, &changedErr) || errors.Is(err, ErrRangeNotSupported) {
+		// Retrying would not help
+		return 0, false
+	}
+
+	var respErr *HTTPResponseError
+	if !errors.As(err, &respErr) {
+		// Network errors can be retried
+		return 0, true
+	}
+
+	switch respErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		maxDelay := p.MaxRetryAfter
+		if maxDelay <= 0 {
+			maxDelay = 2 * time.Minute
+		}
+		return respErr.RetryAfter, respErr.RetryAfter <= maxDelay
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,226 @@
+package webutil
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Router dispatches requests to error-returning Handlers based on the
+// request method and path. It is itself a Handler, and is typically served
+// through Wrap so that errors, including the 404 and 405 errors it returns,
+// are rendered like any other error:
+//
+//	r := webutil.NewRouter()
+//	r.HandleFunc(http.MethodGet, "/users/{id}", getUser)
+//	r.Mount("/admin", adminRouter)
+//	http.Handle("/", webutil.Wrap(r))
+//
+// Patterns are made of slash-separated segments. A segment of the form {name}
+// matches any single non-empty path segment, and a final segment of the form
+// {name...} matches the remainder of the path. Values of these parameters are
+// available through PathParam. Routes are matched in registration order.
+//
+// When a path matches but no route accepts the request's method, the Router
+// answers OPTIONS requests with the list of allowed methods, serves HEAD
+// requests with the GET route, and returns StatusMethodNotAllowed with an
+// Allow header otherwise. Requests matching no route return StatusNotFound.
+type Router struct {
+	routes      []*route
+	middlewares []Middleware
+}
+
+// route is a single entry of a Router.
+type route struct {
+	method   string   // method to match, any if empty
+	segments []string // pattern segments, nil for mounts
+	prefix   string   // path prefix for mounts
+	handler  Handler
+}
+
+// pathParamsKey is the context key holding the path parameters of a request.
+type pathParamsKey struct{}
+
+// NewRouter returns a new, empty Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers h for requests with the given method and path pattern. An
+// empty method matches every method.
+func (r *Router) Handle(method, pattern string, h Handler) {
+	r.routes = append(r.routes, &route{
+		method:   method,
+		segments: strings.Split(strings.TrimPrefix(pattern, "/"), "/"),
+		handler:  h,
+	})
+}
+
+// HandleFunc registers fn for requests with the given method and path
+// pattern. An empty method matches every method.
+func (r *Router) HandleFunc(method, pattern string, fn func(w http.ResponseWriter, req *http.Request) error) {
+	r.Handle(method, pattern, HandlerFunc(fn))
+}
+
+// Mount registers h, typically another Router, for all requests whose path is
+// prefix or starts with prefix followed by a slash. The prefix is removed from
+// the request path before calling h, the same way SkipPrefix does.
+func (r *Router) Mount(prefix string, h Handler) {
+	r.routes = append(r.routes, &route{
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		handler: h,
+	})
+}
+
+// Use adds middlewares applied to every request handled by the Router,
+// including requests resulting in 404 or 405 errors.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// ServeHTTP implements the Handler interface, dispatching the request to the
+// matching route.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
+	if len(r.middlewares) > 0 {
+		return Chain(r.middlewares...).Then(HandlerFunc(r.dispatch)).ServeHTTP(w, req)
+	}
+	return r.dispatch(w, req)
+}
+
+// dispatch finds the route matching the request and calls its handler.
+func (r *Router) dispatch(w http.ResponseWriter, req *http.Request) error {
+	path := req.URL.EscapedPath()
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	var allowed []string
+	var getRoute *route
+	var getParams map[string]string
+
+	for _, rt := range r.routes {
+		if rt.segments == nil {
+			if path == rt.prefix || strings.HasPrefix(path, rt.prefix+"/") {
+				skipPrefix(req, rt.prefix)
+				return rt.handler.ServeHTTP(w, req)
+			}
+			continue
+		}
+
+		params, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+		if rt.method == "" || rt.method == req.Method {
+			return rt.handler.ServeHTTP(w, withPathParams(req, params))
+		}
+		if rt.method == http.MethodGet && getRoute == nil {
+			getRoute, getParams = rt, params
+		}
+		allowed = append(allowed, rt.method)
+	}
+
+	if allowed == nil {
+		return StatusNotFound
+	}
+
+	if req.Method == http.MethodHead && getRoute != nil {
+		// net/http discards the body of responses to HEAD requests
+		return getRoute.handler.ServeHTTP(w, withPathParams(req, getParams))
+	}
+
+	allow := allowHeader(allowed)
+	if req.Method == http.MethodOptions {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return NewHTTPError(http.StatusMethodNotAllowed, "").WithHeader("Allow", allow)
+}
+
+// match reports whether the route's pattern matches the given escaped path
+// segments, returning the values of the pattern's parameters.
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	var params map[string]string
+	setParam := func(name, value string) bool {
+		v, err := url.PathUnescape(value)
+		if err != nil {
+			return false
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = v
+		return true
+	}
+
+	for i, pat := range rt.segments {
+		if strings.HasPrefix(pat, "{") && strings.HasSuffix(pat, "...}") && i == len(rt.segments)-1 {
+			// Wildcard matching the rest of the path
+			rest := ""
+			if i < len(segments) {
+				rest = strings.Join(segments[i:], "/")
+			}
+			return params, setParam(pat[1:len(pat)-4], rest)
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(pat, "{") && strings.HasSuffix(pat, "}") {
+			if segments[i] == "" || !setParam(pat[1:len(pat)-1], segments[i]) {
+				return nil, false
+			}
+			continue
+		}
+		if pat != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, len(segments) == len(rt.segments)
+}
+
+// allowHeader returns the value of the Allow header for the given methods,
+// adding HEAD when GET is allowed and OPTIONS.
+func allowHeader(methods []string) string {
+	set := map[string]bool{http.MethodOptions: true}
+	for _, m := range methods {
+		set[m] = true
+		if m == http.MethodGet {
+			set[http.MethodHead] = true
+		}
+	}
+
+	list := make([]string, 0, len(set))
+	for m := range set {
+		list = append(list, m)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+// withPathParams returns req with params added to its path parameters,
+// keeping the parameters set by enclosing routers.
+func withPathParams(req *http.Request, params map[string]string) *http.Request {
+	if len(params) == 0 {
+		return req
+	}
+	if parent, ok := req.Context().Value(pathParamsKey{}).(map[string]string); ok {
+		merged := make(map[string]string, len(parent)+len(params))
+		for k, v := range parent {
+			merged[k] = v
+		}
+		for k, v := range params {
+			merged[k] = v
+		}
+		params = merged
+	}
+	return req.WithContext(context.WithValue(req.Context(), pathParamsKey{}, params))
+}
+
+// PathParam returns the value of the named path parameter matched by a
+// Router, or an empty string if there is no such parameter.
+func PathParam(req *http.Request, name string) string {
+	params, _ := req.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
@@ -0,0 +1,100 @@
+package webutil_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+func TestRouter(t *testing.T) {
+	r := webutil.NewRouter()
+	r.HandleFunc(http.MethodGet, "/users/{id}", func(w http.ResponseWriter, req *http.Request) error {
+		fmt.Fprintf(w, "user %s", webutil.PathParam(req, "id"))
+		return nil
+	})
+	r.HandleFunc(http.MethodDelete, "/users/{id}", func(w http.ResponseWriter, req *http.Request) error {
+		return webutil.StatusForbidden
+	})
+	r.HandleFunc(http.MethodGet, "/files/{path...}", func(w http.ResponseWriter, req *http.Request) error {
+		fmt.Fprintf(w, "file %s", webutil.PathParam(req, "path"))
+		return nil
+	})
+
+	sub := webutil.NewRouter()
+	sub.HandleFunc(http.MethodGet, "/", func(w http.ResponseWriter, req *http.Request) error {
+		fmt.Fprint(w, "admin home")
+		return nil
+	})
+	sub.HandleFunc("", "/users/{id}", func(w http.ResponseWriter, req *http.Request) error {
+		fmt.Fprintf(w, "admin user %s via %s", webutil.PathParam(req, "id"), req.Header.Get("Sec-Access-Prefix"))
+		return nil
+	})
+	r.Mount("/admin", sub)
+
+	h := webutil.Wrap(r)
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+		body   string
+		allow  string
+	}{
+		{"Param", http.MethodGet, "/users/42", http.StatusOK, "user 42", ""},
+		{"EscapedParam", http.MethodGet, "/users/a%2Fb", http.StatusOK, "user a/b", ""},
+		{"MethodError", http.MethodDelete, "/users/42", http.StatusForbidden, "", ""},
+		{"Wildcard", http.MethodGet, "/files/a/b.txt", http.StatusOK, "file a/b.txt", ""},
+		{"EmptyParam", http.MethodGet, "/users/", http.StatusNotFound, "", ""},
+		{"TooLong", http.MethodGet, "/users/42/extra", http.StatusNotFound, "", ""},
+		{"NotFound", http.MethodGet, "/nowhere", http.StatusNotFound, "", ""},
+		{"MethodNotAllowed", http.MethodPost, "/users/42", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, OPTIONS"},
+		{"Options", http.MethodOptions, "/users/42", http.StatusNoContent, "", "DELETE, GET, HEAD, OPTIONS"},
+		{"Head", http.MethodHead, "/users/42", http.StatusOK, "user 42", ""},
+		{"MountRoot", http.MethodGet, "/admin", http.StatusOK, "admin home", ""},
+		{"MountAnyMethod", http.MethodPut, "/admin/users/7", http.StatusOK, "admin user 7 via /admin", ""},
+		{"MountNotFound", http.MethodGet, "/admin/nowhere", http.StatusNotFound, "", ""},
+		{"MountPrefixOnly", http.MethodGet, "/administrator", http.StatusNotFound, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if rec.Code != tc.code {
+				t.Errorf("Status mismatch: got %d, want %d", rec.Code, tc.code)
+			}
+			if tc.body != "" && rec.Body.String() != tc.body {
+				t.Errorf("Body mismatch: got %q, want %q", rec.Body.String(), tc.body)
+			}
+			if got := rec.Header().Get("Allow"); got != tc.allow {
+				t.Errorf("Allow mismatch: got %q, want %q", got, tc.allow)
+			}
+		})
+	}
+}
+
+func TestRouterUse(t *testing.T) {
+	r := webutil.NewRouter()
+	r.Use(func(next webutil.Handler) webutil.Handler {
+		return webutil.HandlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+			w.Header().Set("X-Middleware", "1")
+			return next.ServeHTTP(w, req)
+		})
+	})
+
+	// Router errors are returned to the caller and pass through middlewares
+	rec := httptest.NewRecorder()
+	err := r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if !errors.Is(err, webutil.StatusNotFound) {
+		t.Errorf("Error mismatch: got %v, want %v", err, webutil.StatusNotFound)
+	}
+	if rec.Header().Get("X-Middleware") != "1" {
+		t.Errorf("Middleware was not applied")
+	}
+}
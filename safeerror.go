@@ -0,0 +1,122 @@
+package webutil
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// HideErrorDetails enables safe mode for errors that do not serve themselves
+// (errors other than HTTPError, Redirect, Problem and other http.Handler
+// implementations).
+//
+// In safe mode, 5xx errors are answered with the generic status text and a
+// correlation ID, also sent as the X-Error-Id header, while the full error is
+// passed to ErrorLogger. 4xx errors only show their message if they implement
+// PublicError, and the standard status text otherwise.
+//
+// It should be set during program initialization, before any request is served.
+var HideErrorDetails bool
+
+// ErrorLogger is called with 5xx errors that do not serve themselves when
+// they are served by ServeError, Wrapper or WrapFunc, and with 5xx
+// StatusError and Problem errors carrying a cause, along with the correlation
+// ID sent to the client. If nil, errors whose details are hidden
+// by HideErrorDetails are logged using the standard log package.
+var ErrorLogger func(req *http.Request, err error, id string)
+
+// PublicError is implemented by errors carrying a message that is safe to
+// display to clients, even when HideErrorDetails is enabled.
+type PublicError interface {
+	error
+	PublicMessage() string
+}
+
+// safeError replaces an error whose details must not be sent to clients.
+type safeError struct {
+	code int
+	msg  string
+}
+
+// Error returns the client-facing message.
+func (e *safeError) Error() string {
+	return e.msg
+}
+
+// HTTPStatus returns the HTTP status code of the replaced error.
+func (e *safeError) HTTPStatus() int {
+	return e.code
+}
+
+// newErrorID returns a random identifier used to correlate errors shown to
+// clients with the logged error.
+func newErrorID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// logServerError passes err to ErrorLogger, or the standard log package if
+// nil, along with a new correlation ID also sent as the X-Error-Id header. It
+// returns the correlation ID.
+func logServerError(w http.ResponseWriter, req *http.Request, err error) string {
+	id := newErrorID()
+	w.Header().Set("X-Error-Id", id)
+
+	if ErrorLogger != nil {
+		ErrorLogger(req, err, id)
+	} else {
+		log.Printf("webutil: error %s serving %s %s: %s", id, req.Method, req.URL.Path, err)
+	}
+	return id
+}
+
+// sanitizeError returns the error to render for err served with the given
+// status code, logging it if needed. The result is err itself unless
+// HideErrorDetails is enabled.
+func sanitizeError(w http.ResponseWriter, req *http.Request, code int, err error) error {
+	if perr, ok := err.(*PanicError); ok && perr.ID != "" {
+		// Recovered panics were already reported to PanicLogger
+		if HideErrorDetails {
+			return &safeError{code: code, msg: fmt.Sprintf("%s (error ID: %s)", statusText(code), perr.ID)}
+		}
+		return err
+	}
+
+	if code >= 500 && (HideErrorDetails || ErrorLogger != nil) {
+		id := logServerError(w, req, err)
+		if HideErrorDetails {
+			return &safeError{code: code, msg: fmt.Sprintf("%s (error ID: %s)", statusText(code), id)}
+		}
+		return err
+	}
+
+	if !HideErrorDetails {
+		return err
+	}
+
+	if members := errorMembers(err); members != nil {
+		// Sanitize each member of aggregates, keeping their position
+		safe := make([]error, len(members))
+		for i, m := range members {
+			if m != nil {
+				safe[i] = sanitizeError(w, req, memberStatus(m), m)
+			}
+		}
+		if _, ok := err.(*MultiStatus); ok {
+			return &MultiStatus{Errors: safe}
+		}
+		return errors.Join(safe...)
+	}
+
+	var pub PublicError
+	if errors.As(err, &pub) {
+		return &safeError{code: code, msg: pub.PublicMessage()}
+	}
+	return &safeError{code: code, msg: statusText(code)}
+}
@@ -0,0 +1,351 @@
+package webutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultSegmentSize is the size of the segments downloaded by GetSegmented
+// and DownloadSegmented when Getter.SegmentSize is not set.
+const DefaultSegmentSize = 4 << 20 // 4MB
+
+// GetSegmented retrieves content like GetContext, using up to
+// Getter.Segments concurrent connections each downloading a segment of
+// Getter.SegmentSize bytes with a Range request. Segments are reassembled in
+// order behind the returned io.ReadCloser, at most Segments of them being
+// buffered in memory at any time, for a total of Segments*SegmentSize bytes.
+//
+// Each segment is resumed on failure like a download started by Get, and the
+// resource is verified to be unchanged using its validators. Resources of
+// unknown size and servers not supporting range requests are downloaded over
+// a single connection instead.
+func (g *Getter) GetSegmented(ctx context.Context, url string) (io.ReadCloser, error) {
+	if strings.HasPrefix(url, "data:") {
+		return g.GetContext(ctx, url)
+	}
+
+	s, err := g.startSegmented(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if s.first.limit == 0 {
+		// Nothing to do in parallel
+		return s.first, nil
+	}
+
+	sr := &segmentReader{
+		ctx:    s.ctx,
+		cancel: s.cancel,
+		queue:  make(chan *segmentBuffer, s.segments),
+		slots:  make(chan struct{}, s.segments),
+	}
+	go sr.dispatch(s)
+	return sr, nil
+}
+
+// DownloadSegmented downloads a resource like GetSegmented, writing each
+// segment to dst at its offset as it is received, and returns the number of
+// bytes written. Writes to dst happen concurrently from several goroutines.
+func (g *Getter) DownloadSegmented(ctx context.Context, url string, dst io.WriterAt) (int64, error) {
+	if strings.HasPrefix(url, "data:") {
+		buf, _, err := ParseDataURI(url)
+		if err != nil {
+			return 0, err
+		}
+		n, err := dst.WriteAt(buf, 0)
+		return int64(n), err
+	}
+
+	s, err := g.startSegmented(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	if s.first.limit == 0 {
+		// Nothing to do in parallel
+		defer s.first.Close()
+		return io.Copy(io.NewOffsetWriter(dst, 0), s.first)
+	}
+	defer s.cancel()
+
+	segs := make(chan *resumeGET)
+	go func() {
+		defer close(segs)
+		for r := s.first; r != nil; r = s.next() {
+			select {
+			case segs <- r:
+			case <-s.ctx.Done():
+				r.Close()
+				return
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		total    int64
+		firstErr error
+	)
+	for i := 0; i < s.segments; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range segs {
+				n, err := io.Copy(io.NewOffsetWriter(dst, r.pos), r)
+				r.Close()
+
+				mu.Lock()
+				total += n
+				if err != nil && firstErr == nil {
+					firstErr = err
+					// Abort the other segments
+					s.cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		// Cancellation might have prevented some segments from starting
+		firstErr = s.ctx.Err()
+	}
+	return total, firstErr
+}
+
+// segmented holds the state of a segmented download.
+type segmented struct {
+	ctx      context.Context    // context of the whole download
+	cancel   context.CancelFunc // cancel method of ctx
+	first    *resumeGET         // download of the first segment
+	offset   int64              // start of the next segment
+	size     int64              // size of segments
+	segments int                // number of concurrent connections
+}
+
+// startSegmented requests the first segment of url. If the resource can be
+// downloaded in segments, the returned first download has a limit, otherwise
+// it is a regular download of the whole resource closing the download's
+// context.
+func (g *Getter) startSegmented(ctx context.Context, url string) (*segmented, error) {
+	s := &segmented{size: g.SegmentSize, segments: g.Segments}
+	if s.size <= 0 {
+		s.size = DefaultSegmentSize
+	}
+	if s.segments <= 0 {
+		s.segments = 4
+	}
+
+	s.ctx, s.cancel = g.downloadContext(ctx)
+	fctx, fcancel := context.WithCancel(s.ctx)
+	first, err := g.newResumeGET(fctx, fcancel, url)
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+	s.first = first
+
+	req := first.req.Clone(fctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", s.size-1))
+	resp, err := first.do(req)
+	if err != nil {
+		s.cancel()
+		return nil, fmt.Errorf("performing request: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		first.start(resp)
+		first.req.Header.Del("Range")
+		cr := resp.Header.Get("Content-Range")
+		start, end, total, ok := parseContentRange(cr)
+		switch {
+		case !ok || start != 0:
+			defer s.cancel()
+			first.Close()
+			return nil, fmt.Errorf("invalid Content-Range %q", cr)
+		case total < 0:
+			// Unknown size, download everything over a single connection
+			first.Close()
+			return g.fallbackSegmented(s, url)
+		}
+		first.size = total
+		if end+1 >= total {
+			// The first segment holds the whole resource
+			first.cancel = s.cancel
+			return s, nil
+		}
+		first.limit = end + 1
+		s.offset = end + 1
+		return s, nil
+	case http.StatusOK, http.StatusNoContent:
+		// The server doesn't support range requests
+		first.start(resp)
+		first.req.Header.Del("Range")
+		first.cancel = s.cancel
+		return s, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Empty resource
+		discardAndCloseBody(resp)
+		first.Close()
+		return g.fallbackSegmented(s, url)
+	default:
+		defer first.Close()
+		defer s.cancel()
+		return nil, ResponseError(resp)
+	}
+}
+
+// fallbackSegmented downloads url over a single connection within the
+// context of s.
+func (g *Getter) fallbackSegmented(s *segmented, url string) (*segmented, error) {
+	body, err := g.GetContext(s.ctx, url)
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+	s.first = body.(*resumeGET)
+	s.first.cancel = s.cancel
+	return s, nil
+}
+
+// next returns the download of the next segment, or nil if there are no
+// more segments.
+func (s *segmented) next() *resumeGET {
+	if s.offset >= s.first.size {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	r := &resumeGET{
+		req:            s.first.req.Clone(ctx),
+		client:         s.first.client,
+		ctx:            ctx,
+		cancel:         cancel,
+		connectTimeout: s.first.connectTimeout,
+		stallTimeout:   s.first.stallTimeout,
+		retry:          s.first.retry,
+		pos:            s.offset,
+		size:           s.first.size,
+		limit:          s.offset + s.size,
+		etag:           s.first.etag,
+		modified:       s.first.modified,
+	}
+	if r.limit > r.size {
+		r.limit = r.size
+	}
+	s.offset = r.limit
+	return r
+}
+
+// segmentBuffer holds the data of a segment downloaded by segmentReader.
+type segmentBuffer struct {
+	done chan struct{} // closed once data and err are set
+	data []byte
+	err  error
+}
+
+// segmentReader reassembles the segments of a segmented download in order.
+type segmentReader struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	queue  chan *segmentBuffer // segments in order
+	slots  chan struct{}       // limits the number of buffered segments
+	buf    []byte              // remaining data of the current segment
+	cur    *segmentBuffer      // current segment, holding a slot
+	err    error               // final error, returned by all subsequent reads
+}
+
+// dispatch starts downloading the segments of s in order, as long as slots
+// are available.
+func (sr *segmentReader) dispatch(s *segmented) {
+	for r := s.first; r != nil; r = s.next() {
+		select {
+		case sr.slots <- struct{}{}:
+		case <-sr.ctx.Done():
+			r.Close()
+			return
+		}
+
+		seg := &segmentBuffer{done: make(chan struct{})}
+		go func(r *resumeGET) {
+			defer close(seg.done)
+			defer r.Close()
+			buf := make([]byte, r.limit-r.pos)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				seg.err = err
+				return
+			}
+			// The segment must end where expected
+			var extra [1]byte
+			if _, err := r.Read(extra[:]); err != io.EOF {
+				if err == nil {
+					err = fmt.Errorf("segment ending at offset %d longer than expected", r.limit)
+				}
+				seg.err = err
+				return
+			}
+			seg.data = buf
+		}(r)
+
+		// The queue has room for all segments holding a slot
+		sr.queue <- seg
+	}
+	close(sr.queue)
+}
+
+// Read implements io.Reader, returning the segments in order.
+func (sr *segmentReader) Read(b []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.cur != nil {
+			// Done with this segment, let another one start
+			sr.cur = nil
+			<-sr.slots
+		}
+
+		var seg *segmentBuffer
+		var ok bool
+		select {
+		case seg, ok = <-sr.queue:
+		case <-sr.ctx.Done():
+			sr.err = sr.ctx.Err()
+			continue
+		}
+		if !ok {
+			sr.err = io.EOF
+			continue
+		}
+
+		select {
+		case <-seg.done:
+		case <-sr.ctx.Done():
+			sr.err = sr.ctx.Err()
+			continue
+		}
+		if seg.err != nil {
+			sr.err = seg.err
+			sr.cancel()
+			continue
+		}
+		sr.cur = seg
+		sr.buf = seg.data
+	}
+
+	n := copy(b, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+// Close implements io.Closer, aborting the download.
+func (sr *segmentReader) Close() error {
+	sr.cancel()
+	return nil
+}
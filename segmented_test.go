@@ -0,0 +1,173 @@
+package webutil_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+// memWriterAt is an io.WriterAt writing to memory.
+type memWriterAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *memWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	return copy(w.buf[off:], p), nil
+}
+
+func TestGetSegmented(t *testing.T) {
+	content := make([]byte, 100000)
+	for i := range content {
+		content[i] = byte(i * 7 / 3)
+	}
+	const segmentSize = 10000
+
+	testCases := []struct {
+		name     string
+		requests int32
+		changed  bool
+	}{
+		{"Ranges", 10, false},
+		{"Interrupted", 11, false},
+		{"NoRanges", 1, false},
+		{"NoRangesInterrupted", 2, false},
+		{"Small", 1, false},
+		{"Changed", 0, true},
+	}
+
+	for _, tc := range testCases {
+		for _, mode := range []string{"Reader", "Writer"} {
+			t.Run(tc.name+"/"+mode, func(t *testing.T) {
+				var requests, interrupted int32
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					n := atomic.AddInt32(&requests, 1)
+					data := content
+					switch tc.name {
+					case "NoRanges":
+						w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+						_, _ = w.Write(content)
+						return
+					case "NoRangesInterrupted":
+						if n > 1 && req.Header.Get("Range") != "" {
+							http.Error(w, "unexpected Range header", http.StatusBadRequest)
+							return
+						}
+						w.Header().Set("Accept-Ranges", "none")
+						w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+						if n == 1 {
+							_, _ = w.Write(content[:len(content)/2])
+							w.(http.Flusher).Flush()
+							panic(http.ErrAbortHandler)
+						}
+						_, _ = w.Write(content)
+						return
+					case "Small":
+						data = content[:segmentSize/2]
+					case "Interrupted":
+						if req.Header.Get("Range") == fmt.Sprintf("bytes=%d-%d", 2*segmentSize, 3*segmentSize-1) && atomic.AddInt32(&interrupted, 1) == 1 {
+							w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", 2*segmentSize, 3*segmentSize-1, len(content)))
+							w.Header().Set("Content-Length", strconv.Itoa(segmentSize))
+							w.WriteHeader(http.StatusPartialContent)
+							_, _ = w.Write(content[2*segmentSize : 2*segmentSize+100])
+							w.(http.Flusher).Flush()
+							panic(http.ErrAbortHandler)
+						}
+					case "Changed":
+						if n > 1 {
+							w.Header().Set("ETag", `"v2"`)
+							data = bytes.ToUpper(content)
+						}
+					}
+					if w.Header().Get("ETag") == "" {
+						w.Header().Set("ETag", `"v1"`)
+					}
+					http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(data))
+				}))
+				defer srv.Close()
+
+				g := &webutil.Getter{Segments: 3, SegmentSize: segmentSize, MaxSkip: -1}
+				want := content
+				if tc.name == "Small" {
+					want = content[:segmentSize/2]
+				}
+
+				var data []byte
+				var err error
+				if mode == "Reader" {
+					var body io.ReadCloser
+					body, err = g.GetSegmented(context.Background(), srv.URL)
+					if err != nil {
+						t.Fatalf("GetSegmented failed: %v", err)
+					}
+					data, err = io.ReadAll(body)
+					body.Close()
+				} else {
+					w := &memWriterAt{}
+					var n int64
+					n, err = g.DownloadSegmented(context.Background(), srv.URL, w)
+					data = w.buf
+					if err == nil && n != int64(len(want)) {
+						t.Errorf("DownloadSegmented returned %d, want %d", n, len(want))
+					}
+				}
+
+				if tc.changed {
+					var changedErr *webutil.ResourceChangedError
+					if !errors.As(err, &changedErr) {
+						t.Errorf("Error: got %v, want ResourceChangedError", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Download failed: %v", err)
+				}
+				if !bytes.Equal(data, want) {
+					t.Errorf("Content mismatch: got %d bytes, want %d", len(data), len(want))
+				}
+				if n := atomic.LoadInt32(&requests); n != tc.requests {
+					t.Errorf("Expected %d requests, got %d", tc.requests, n)
+				}
+			})
+		}
+	}
+}
+
+func TestGetSegmentedCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Range", "bytes 0-9/100")
+		w.WriteHeader(http.StatusPartialContent)
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &webutil.Getter{SegmentSize: 10}
+	body, err := g.GetSegmented(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("GetSegmented failed: %v", err)
+	}
+	defer body.Close()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := io.ReadAll(body); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadAll error: got %v, want context.Canceled", err)
+	}
+}
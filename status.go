@@ -37,6 +37,10 @@ const (
 	StatusRequestHeaderFieldsTooLarge  = HTTPError(http.StatusRequestHeaderFieldsTooLarge)
 	StatusUnavailableForLegalReasons   = HTTPError(http.StatusUnavailableForLegalReasons)
 
+	// StatusClientClosedRequest is the non-standard 499 code used by nginx
+	// when the client closes the connection before the response is sent.
+	StatusClientClosedRequest = HTTPError(499)
+
 	// 5xx Server Errors
 	StatusInternalServerError           = HTTPError(http.StatusInternalServerError)
 	StatusNotImplemented                = HTTPError(http.StatusNotImplemented)
@@ -50,3 +54,12 @@ const (
 	StatusNotExtended                   = HTTPError(http.StatusNotExtended)
 	StatusNetworkAuthenticationRequired = HTTPError(http.StatusNetworkAuthenticationRequired)
 )
+
+// statusText returns the text for the HTTP status code, including the
+// non-standard codes defined by this package.
+func statusText(code int) string {
+	if code == int(StatusClientClosedRequest) {
+		return "Client Closed Request"
+	}
+	return http.StatusText(code)
+}
@@ -0,0 +1,108 @@
+package webutil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// StatusError is an HTTP error carrying a client-facing message, additional
+// response headers and an underlying cause.
+//
+// It matches its HTTPError code with errors.Is, so that
+// errors.Is(NewHTTPError(404, "invoice not found"), StatusNotFound) is true.
+type StatusError struct {
+	Code    HTTPError   // HTTP status code
+	Message string      // Message displayed to the client, status text if empty
+	Header  http.Header // Headers added to the response
+	Cause   error       // Underlying error, never displayed to the client but logged for 5xx codes
+}
+
+// NewHTTPError creates a StatusError for the given status code and
+// client-facing message.
+//
+// Example:
+//
+//	return webutil.NewHTTPError(http.StatusTooManyRequests, "slow down").
+//	    WithHeader("Retry-After", "30")
+func NewHTTPError(code int, msg string) *StatusError {
+	return &StatusError{Code: HTTPError(code), Message: msg}
+}
+
+// WithHeader adds a header to be sent with the error response and returns e.
+func (e *StatusError) WithHeader(key, value string) *StatusError {
+	if e.Header == nil {
+		e.Header = make(http.Header)
+	}
+	e.Header.Add(key, value)
+	return e
+}
+
+// WithCause sets the underlying cause of the error and returns e. The cause
+// is not displayed to the client, but errors with a 5xx code and a cause are
+// passed to ErrorLogger when served.
+func (e *StatusError) WithCause(err error) *StatusError {
+	e.Cause = err
+	return e
+}
+
+// Error returns the status code and message, followed by the cause if any.
+func (e *StatusError) Error() string {
+	msg := e.Code.Error()
+	if e.Message != "" {
+		msg = fmt.Sprintf("HTTP error %d: %s", int(e.Code), e.Message)
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+// PublicMessage returns the message displayed to clients.
+func (e *StatusError) PublicMessage() string {
+	if e.Message == "" {
+		return statusText(int(e.Code))
+	}
+	return e.Message
+}
+
+// HTTPStatus returns the HTTP status code of the error.
+func (e *StatusError) HTTPStatus() int {
+	return int(e.Code)
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *StatusError) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether target matches the error's HTTPError code, including
+// the standard errors that code maps to.
+func (e *StatusError) Is(target error) bool {
+	return errors.Is(e.Code, target)
+}
+
+// errorHeader returns the headers added to the response.
+func (e *StatusError) errorHeader() http.Header {
+	return e.Header
+}
+
+// ServeHTTP implements the http.Handler interface, adding the error's headers
+// to the response before rendering it with the request's ErrorRenderer.
+// Server errors with a cause are logged like errors hidden by
+// HideErrorDetails.
+func (e *StatusError) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if e.Code >= 500 && e.Cause != nil {
+		logServerError(w, req, e)
+	}
+
+	h := w.Header()
+	for k, v := range e.errorHeader() {
+		h[k] = append(h[k], v...)
+	}
+	if e.Code == http.StatusUnauthorized && h.Get("WWW-Authenticate") == "" {
+		setDefaultChallenge(h)
+	}
+
+	renderError(w, req, int(e.Code), e)
+}
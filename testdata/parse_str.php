@@ -0,0 +1,15 @@
+<?php
+// Prints the result of parse_str for each query read from standard input, one
+// per line, as a JSON object holding the query and the resulting array:
+//
+//	printf 'a[]=1&a[]=2\n' | php testdata/parse_str.php
+//
+// TestParsePhpStrPHP runs it with the queries of TestParsePhpStr to check
+// ParsePhpStr against PHP 8.3 or later, whose next-index semantics for
+// negative keys differ from earlier versions.
+
+while (($query = fgets(STDIN)) !== false) {
+    $query = rtrim($query, "\n");
+    parse_str($query, $result);
+    echo json_encode(['query' => $query, 'result' => $result], JSON_UNESCAPED_SLASHES | JSON_UNESCAPED_UNICODE), "\n";
+}
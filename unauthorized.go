@@ -0,0 +1,210 @@
+package webutil
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultAuthChallenge is the WWW-Authenticate header value sent with 401
+// errors that do not specify their own challenge, such as StatusUnauthorized.
+// Set it to an empty string to disable the default challenge, for example
+// when serving APIs called from browser scripts where a Basic challenge
+// would trigger the browser's login prompt.
+//
+// It should be set during program initialization, before any request is served.
+var DefaultAuthChallenge = `Basic realm="Website Access"`
+
+// setDefaultChallenge sets the WWW-Authenticate header sent with 401 errors
+// that do not specify their own challenge.
+func setDefaultChallenge(h http.Header) {
+	if DefaultAuthChallenge != "" {
+		h.Set("WWW-Authenticate", DefaultAuthChallenge)
+	}
+}
+
+// AuthParam is a parameter of an authentication challenge.
+type AuthParam struct {
+	Name  string
+	Value string
+
+	// Token sends Value as a token rather than a quoted string, as expected
+	// for parameters such as Digest's algorithm and stale. Values that are
+	// not valid tokens are quoted anyway.
+	Token bool
+}
+
+// Challenge is an authentication challenge sent in a WWW-Authenticate header,
+// as defined by RFC 9110 section 11.
+type Challenge struct {
+	Scheme string      // Authentication scheme, such as "Basic" or "Bearer"
+	Params []AuthParam // Parameters, sent in order
+}
+
+// BasicChallenge returns a Basic authentication challenge (RFC 7617) for the
+// given realm.
+func BasicChallenge(realm string) Challenge {
+	return Challenge{
+		Scheme: "Basic",
+		Params: []AuthParam{{Name: "realm", Value: realm}, {Name: "charset", Value: "UTF-8"}},
+	}
+}
+
+// BearerChallenge returns a Bearer token challenge as defined by RFC 6750.
+// The errCode (such as "invalid_token" or "insufficient_scope"), description
+// and scope parameters are only included when not empty.
+func BearerChallenge(realm, errCode, description, scope string) Challenge {
+	c := Challenge{Scheme: "Bearer"}
+	c.add("realm", realm)
+	c.add("scope", scope)
+	c.add("error", errCode)
+	c.add("error_description", description)
+	return c
+}
+
+// DigestChallenge returns a Digest authentication challenge (RFC 7616) with
+// the given realm and nonce, offering the "auth" quality of protection with
+// the SHA-256 algorithm. A stale parameter can be appended as a token
+// parameter when the client used an expired nonce.
+func DigestChallenge(realm, nonce, opaque string) Challenge {
+	c := Challenge{Scheme: "Digest"}
+	c.add("realm", realm)
+	c.add("qop", "auth")
+	c.Params = append(c.Params, AuthParam{Name: "algorithm", Value: "SHA-256", Token: true})
+	c.add("nonce", nonce)
+	c.add("opaque", opaque)
+	return c
+}
+
+// add appends a parameter to the challenge if its value is not empty.
+func (c *Challenge) add(name, value string) {
+	if value != "" {
+		c.Params = append(c.Params, AuthParam{Name: name, Value: value})
+	}
+}
+
+// String returns the challenge formatted for a WWW-Authenticate header.
+func (c Challenge) String() string {
+	var b strings.Builder
+	b.WriteString(c.Scheme)
+
+	for i, p := range c.Params {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(p.Name)
+		b.WriteByte('=')
+		if p.Token && isToken(p.Value) {
+			b.WriteString(p.Value)
+		} else {
+			writeQuoted(&b, p.Value)
+		}
+	}
+
+	return b.String()
+}
+
+// isToken reports whether s is a non-empty RFC 9110 token.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// writeQuoted writes s as an RFC 9110 quoted-string, escaping backslashes and
+// double quotes. Control characters other than tabs are not allowed and are
+// dropped.
+func writeQuoted(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\t' || (c >= 0x20 && c != 0x7f):
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+}
+
+// Unauthorized is a 401 Unauthorized error carrying the authentication
+// challenges to send to the client, each in its own WWW-Authenticate header.
+// When it has no challenges, DefaultAuthChallenge is used.
+//
+// It matches StatusUnauthorized with errors.Is.
+type Unauthorized struct {
+	Challenges []Challenge
+	Message    string // Message displayed to the client, status text if empty
+}
+
+// NewUnauthorized returns an Unauthorized error with the given challenges.
+//
+// Example:
+//
+//	return webutil.NewUnauthorized(webutil.BearerChallenge("api", "invalid_token", "token expired", ""))
+func NewUnauthorized(challenges ...Challenge) *Unauthorized {
+	return &Unauthorized{Challenges: challenges}
+}
+
+// Error returns a description of the error.
+func (e *Unauthorized) Error() string {
+	if e.Message != "" {
+		return "HTTP error 401: " + e.Message
+	}
+	return StatusUnauthorized.Error()
+}
+
+// PublicMessage returns the message displayed to clients.
+func (e *Unauthorized) PublicMessage() string {
+	if e.Message == "" {
+		return statusText(http.StatusUnauthorized)
+	}
+	return e.Message
+}
+
+// HTTPStatus returns http.StatusUnauthorized.
+func (e *Unauthorized) HTTPStatus() int {
+	return http.StatusUnauthorized
+}
+
+// Unwrap returns StatusUnauthorized.
+func (e *Unauthorized) Unwrap() error {
+	return StatusUnauthorized
+}
+
+// errorHeader returns the WWW-Authenticate headers of the challenges.
+func (e *Unauthorized) errorHeader() http.Header {
+	h := make(http.Header)
+	for _, c := range e.Challenges {
+		h.Add("WWW-Authenticate", c.String())
+	}
+	return h
+}
+
+// ServeHTTP implements the http.Handler interface, sending the challenges
+// before rendering the error with the request's ErrorRenderer.
+func (e *Unauthorized) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h := w.Header()
+	h.Del("WWW-Authenticate")
+	for k, v := range e.errorHeader() {
+		h[k] = append(h[k], v...)
+	}
+	if len(e.Challenges) == 0 {
+		setDefaultChallenge(h)
+	}
+
+	renderError(w, req, http.StatusUnauthorized, e)
+}
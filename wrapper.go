@@ -1,6 +1,9 @@
 package webutil
 
-import "net/http"
+import (
+	"log"
+	"net/http"
+)
 
 // Handler extends the standard http.Handler interface by allowing
 // ServeHTTP to return an error. This enables more flexible error handling
@@ -14,30 +17,45 @@ type Handler interface {
 // converting them to HTTP responses.
 type Wrapper struct {
 	Child Handler // The wrapped Handler that may return errors
+
+	// Renderer, if set, renders errors served while handling requests through
+	// this Wrapper, including errors served by the Child itself through
+	// ServeError. DefaultErrorRenderer is used otherwise.
+	Renderer ErrorRenderer
+
+	// Recover enables panic recovery for this Wrapper, see RecoverPanics.
+	Recover bool
 }
 
 // WrapFunc is a function type that implements the Handler interface.
 // It's similar to http.HandlerFunc but can return an error.
 type WrapFunc func(w http.ResponseWriter, req *http.Request) error
 
+// HandlerFunc is an adapter allowing ordinary functions to be used as a
+// Handler. Unlike WrapFunc, which is used as a standard http.Handler,
+// HandlerFunc implements the error-returning Handler interface itself, and is
+// typically used with Middleware.
+type HandlerFunc func(w http.ResponseWriter, req *http.Request) error
+
+// ServeHTTP calls f(w, req).
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
+	return f(w, req)
+}
+
 // ServeHTTP implements the http.Handler interface by calling the wrapped Handler
 // and handling any returned errors by converting them to appropriate HTTP responses.
 func (wrapper *Wrapper) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	err := wrapper.Child.ServeHTTP(w, req)
-	if err != nil {
-		// Convert the error to an HTTP handler and serve the response
-		ErrorToHTTPHandler(err).ServeHTTP(w, req)
+	if wrapper.Renderer != nil {
+		req = req.WithContext(WithErrorRenderer(req.Context(), wrapper.Renderer))
 	}
+
+	serveHandler(wrapper.Child.ServeHTTP, w, req, wrapper.Recover || RecoverPanics)
 }
 
 // ServeHTTP implements the http.Handler interface for WrapFunc, allowing
 // it to be used directly as an http.Handler while still returning errors.
 func (wf WrapFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	err := wf(w, req)
-	if err != nil {
-		// Convert the error to an HTTP handler and serve the response
-		ErrorToHTTPHandler(err).ServeHTTP(w, req)
-	}
+	serveHandler(wf, w, req, RecoverPanics)
 }
 
 // Wrap converts a Handler to a standard http.Handler by wrapping it
@@ -45,3 +63,42 @@ func (wf WrapFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 func Wrap(h Handler) http.Handler {
 	return &Wrapper{Child: h}
 }
+
+// serveHandler calls h and serves the error it returns, if any. If
+// recoverPanics is set, panics in h are recovered and served as errors.
+//
+// The response writer is wrapped to detect errors returned after the response
+// was started, which are handled by serveStartedError.
+func serveHandler(h func(http.ResponseWriter, *http.Request) error, w http.ResponseWriter, req *http.Request, recoverPanics bool) {
+	tw := &trackingWriter{ResponseWriter: w}
+
+	var err error
+	if recoverPanics {
+		err = callRecover(h, tw, req)
+	} else {
+		err = h(tw, req)
+	}
+	if err == nil {
+		return
+	}
+
+	if perr, ok := err.(*PanicError); ok {
+		perr.ID = newErrorID()
+		if !tw.started {
+			w.Header().Set("X-Error-Id", perr.ID)
+		}
+		if PanicLogger != nil {
+			PanicLogger(req, perr)
+		} else {
+			log.Printf("webutil: panic %s serving %s %s: %v\n%s", perr.ID, req.Method, req.URL.Path, perr.Value, perr.Stack)
+		}
+	}
+
+	if tw.started {
+		serveStartedError(tw, req, err)
+		return
+	}
+
+	// Convert the error to an HTTP handler and serve the response
+	ErrorToHTTPHandler(err).ServeHTTP(w, req)
+}
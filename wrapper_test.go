@@ -0,0 +1,179 @@
+package webutil_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/webutil"
+)
+
+func TestWrapperRecover(t *testing.T) {
+	var recovered *webutil.PanicError
+	webutil.PanicLogger = func(req *http.Request, err *webutil.PanicError) {
+		recovered = err
+	}
+	defer func() { webutil.PanicLogger = nil }()
+
+	t.Run("Panic before writing", func(t *testing.T) {
+		recovered = nil
+		h := &webutil.Wrapper{
+			Child: handlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+				var m map[string]int
+				m["boom"]++ // nil map assignment
+				return nil
+			}),
+			Recover: true,
+		}
+
+		rec := serve(h, "")
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Status mismatch: got %d, want %d", rec.Code, http.StatusInternalServerError)
+		}
+		if recovered == nil || len(recovered.Stack) == 0 {
+			t.Fatalf("PanicLogger was not called with a stack trace")
+		}
+	})
+
+	t.Run("Panic with hidden details", func(t *testing.T) {
+		recovered = nil
+		var logged int
+		webutil.HideErrorDetails = true
+		webutil.ErrorLogger = func(req *http.Request, err error, id string) { logged++ }
+		defer func() {
+			webutil.HideErrorDetails = false
+			webutil.ErrorLogger = nil
+		}()
+
+		h := &webutil.Wrapper{
+			Child: handlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+				panic("boom")
+			}),
+			Recover: true,
+		}
+
+		rec := serve(h, "")
+		if recovered == nil || recovered.ID == "" {
+			t.Fatalf("PanicLogger was not called with a correlation ID")
+		}
+		if logged != 0 {
+			t.Errorf("ErrorLogger called %d times for a recovered panic", logged)
+		}
+		if id := rec.Header().Get("X-Error-Id"); id != recovered.ID || !strings.Contains(rec.Body.String(), id) {
+			t.Errorf("Correlation ID mismatch: header %q, logged %q, body %q", id, recovered.ID, rec.Body.String())
+		}
+	})
+
+	t.Run("Panic after writing", func(t *testing.T) {
+		h := &webutil.Wrapper{
+			Child: handlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+				_, _ = io.WriteString(w, "partial")
+				panic("boom")
+			}),
+			Recover: true,
+		}
+
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("Recovered %v, want http.ErrAbortHandler", v)
+			}
+		}()
+		serve(h, "")
+	})
+
+	t.Run("Abort handler", func(t *testing.T) {
+		recovered = nil
+		webutil.RecoverPanics = true
+		defer func() { webutil.RecoverPanics = false }()
+
+		h := webutil.WrapFunc(func(w http.ResponseWriter, req *http.Request) error {
+			panic(http.ErrAbortHandler)
+		})
+
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("Recovered %v, want http.ErrAbortHandler", v)
+			}
+			if recovered != nil {
+				t.Errorf("PanicLogger called for http.ErrAbortHandler")
+			}
+		}()
+		serve(h, "")
+	})
+
+	t.Run("Panic with error", func(t *testing.T) {
+		webutil.RecoverPanics = true
+		defer func() { webutil.RecoverPanics = false }()
+
+		sentinel := errors.New("sentinel")
+		rec := serve(webutil.WrapFunc(func(w http.ResponseWriter, req *http.Request) error {
+			panic(sentinel)
+		}), "")
+		if rec.Code != http.StatusInternalServerError || rec.Body.String() != "panic: sentinel" {
+			t.Errorf("Response mismatch: got %d %q", rec.Code, rec.Body.String())
+		}
+		if !errors.Is(recovered, sentinel) {
+			t.Errorf("errors.Is(recovered, sentinel) = false, want true")
+		}
+	})
+}
+
+// handlerFunc adapts a function to the webutil.Handler interface.
+type handlerFunc func(w http.ResponseWriter, req *http.Request) error
+
+func (f handlerFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) error {
+	return f(w, req)
+}
+
+func TestWrapperErrorAfterWrite(t *testing.T) {
+	var logged error
+	webutil.ErrorLogger = func(req *http.Request, err error, id string) {
+		logged = err
+	}
+	defer func() { webutil.ErrorLogger = nil }()
+
+	failing := webutil.WrapFunc(func(w http.ResponseWriter, req *http.Request) error {
+		_, _ = io.WriteString(w, "partial body")
+		return webutil.StatusBadGateway
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("Recovered %v, want http.ErrAbortHandler", v)
+			}
+			if logged != webutil.StatusBadGateway {
+				t.Errorf("Logged %v, want %v", logged, webutil.StatusBadGateway)
+			}
+		}()
+		serve(failing, "")
+	})
+
+	t.Run("Trailer", func(t *testing.T) {
+		webutil.ErrorTrailer = "X-Error"
+		defer func() { webutil.ErrorTrailer = "" }()
+
+		srv := httptest.NewServer(failing)
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Reading body failed: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || string(body) != "partial body" {
+			t.Errorf("Response mismatch: got %d %q", resp.StatusCode, body)
+		}
+		if tr := resp.Trailer.Get("X-Error"); tr != "502 HTTP error 502: Bad Gateway" {
+			t.Errorf("Trailer mismatch: got %q", tr)
+		}
+	})
+}